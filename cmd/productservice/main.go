@@ -1,31 +1,59 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
 
+	"enchanted-micro/internal/pkg/apikey"
+	"enchanted-micro/internal/pkg/health"
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/jwks"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/requestid"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/pkg/tracing"
+	"enchanted-micro/internal/pkg/userdata"
 	"enchanted-micro/internal/productservice/config"
 	"enchanted-micro/internal/productservice/database"
 	"enchanted-micro/internal/productservice/handlers"
 	"enchanted-micro/internal/productservice/middleware"
+	"enchanted-micro/internal/productservice/sellers"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	// Config yükle
 	cfg := config.LoadConfig()
+	logger.Init("product-service", cfg.LogLevel, cfg.LogFormat)
+	if _, err := tracing.Init(context.Background(), "product-service", cfg.TraceExporter); err != nil {
+		logger.Fatal("tracing setup failed", "error", err)
+	}
 
 	// Database bağlantısı
 	database.ConnectDB(cfg)
 
 	// Gin router
-	r := gin.Default()
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	// Loglardaki istemci IP'si ClientIP'den okunur; X-Forwarded-For
+	// zincirlerine yalnızca gateway'den gelince güvenilir
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("invalid TRUSTED_PROXIES", "error", err)
+	}
+	r.Use(otelgin.Middleware("product-service"), requestid.Middleware(), logger.Middleware(), metrics.Middleware(), gin.Recovery())
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -38,24 +66,56 @@ func main() {
 	// Static files for uploads
 	r.Static("/uploads", cfg.UploadPath)
 
+	// Çıkış yapılmış token listesi user service'ten senkronize edilir
+	// API anahtarları da user service'e sorulur
+	var revocations identity.RevocationChecker
+	var apiKeys identity.APIKeyVerifier
+	if cfg.InternalAPISecret != "" {
+		cache := revocation.NewCache(revocation.HTTPSource(cfg.UserServiceURL, cfg.InternalAPISecret, &http.Client{Timeout: 5 * time.Second}))
+		go cache.Run(context.Background(), cfg.RevocationSyncInterval)
+		revocations = cache
+		apiKeys = apikey.NewClient(cfg.UserServiceURL, cfg.InternalAPISecret, &http.Client{Timeout: 5 * time.Second}, cfg.APIKeyCacheTTL)
+	} else {
+		slog.Warn("INTERNAL_API_SECRET not set, logged out tokens stay valid until they expire and API keys are rejected")
+	}
+
+	// Token imzaları user service'in JWKS'inden alınan anahtarlarla doğrulanır
+	keys := jwks.NewClient(cfg.JWKSURL, &http.Client{Timeout: 5 * time.Second})
+
+	// Satıcı profilleri user service'in herkese açık endpoint'inden alınır
+	sellerProfiles := sellers.NewClient(cfg.UserServiceURL, &http.Client{Timeout: 2 * time.Second}, cfg.SellerCacheTTL)
+
 	// Product handler
-	productHandler := handlers.NewProductHandler(cfg)
+	productHandler := handlers.NewProductHandler(cfg, sellerProfiles)
 
-	// Public routes
+	// Public routes; ?include=seller ile satıcı profilleri eklenir
 	r.GET("/products", productHandler.GetProducts)
 
 	// Protected routes
 	protected := r.Group("/")
-	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.AuthMiddleware(cfg, keys, revocations, apiKeys))
 	{
-		// Product CRUD
-		protected.POST("/products", productHandler.CreateProduct)
-		protected.GET("/my-products", productHandler.GetMyProducts)
-		protected.PUT("/products/:id", productHandler.UpdateProduct)
-		protected.DELETE("/products/:id", productHandler.DeleteProduct)
-		
+		// Product CRUD; API anahtarları kapsamlarına göre kullanılabilir
+		protected.POST("/products", identity.RequireScope(identity.ScopeProductsWrite), middleware.RequireVerifiedEmail(cfg), productHandler.CreateProduct)
+		protected.GET("/my-products", identity.RequireScope(identity.ScopeProductsRead), productHandler.GetMyProducts)
+		protected.PUT("/products/:id", identity.RequireScope(identity.ScopeProductsWrite), productHandler.UpdateProduct)
+		protected.DELETE("/products/:id", identity.RequireScope(identity.ScopeProductsWrite), productHandler.DeleteProduct)
+
+		// Moderasyon
+		protected.POST("/products/:id/hide", identity.RequirePermission(identity.PermProductsModerate), productHandler.HideProduct)
+		protected.POST("/products/:id/unhide", identity.RequirePermission(identity.PermProductsModerate), productHandler.UnhideProduct)
+
 		// Image upload
-		protected.POST("/products/:id/image", productHandler.UploadProductImage)
+		protected.POST("/products/:id/image", identity.RequireScope(identity.ScopeProductsWrite), productHandler.UploadProductImage)
+	}
+
+	// Servisler arası endpoint'ler (INTERNAL_API_SECRET ile korunur): veri
+	// dışa aktarımı ve hesap silme için kullanıcının ürünleri
+	internal := r.Group("/internal")
+	internal.Use(internalapi.Middleware(cfg.InternalAPISecret))
+	{
+		internal.GET("/users/:id/data", userdata.ExportHandler(productHandler.ExportUserData))
+		internal.DELETE("/users/:id/data", userdata.EraseHandler(productHandler.EraseUserData))
 	}
 
 	// Health check
@@ -63,8 +123,17 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok", "service": "product-service"})
 	})
 
-	log.Printf("Product Service %s portunda başlatılıyor...", cfg.Port)
+	// Liveness ve readiness
+	healthChecker := health.New("product-service")
+	healthChecker.Add("database", health.Database(database.GetDB))
+	healthChecker.Add("uploads", health.WritableDir(cfg.UploadPath))
+	healthChecker.Register(r)
+
+	// Prometheus metrikleri
+	metrics.Register(r)
+
+	slog.Info("server starting", "port", cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Server başlatılamadı:", err)
+		logger.Fatal("server failed", "error", err)
 	}
 }
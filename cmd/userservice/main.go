@@ -1,31 +1,65 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"time"
 
+	"enchanted-micro/internal/pkg/apikey"
+	"enchanted-micro/internal/pkg/health"
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/jwks"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/requestid"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/pkg/tracing"
+	"enchanted-micro/internal/userservice/account"
+	"enchanted-micro/internal/userservice/avatar"
 	"enchanted-micro/internal/userservice/config"
 	"enchanted-micro/internal/userservice/database"
 	"enchanted-micro/internal/userservice/handlers"
+	"enchanted-micro/internal/userservice/keys"
+	"enchanted-micro/internal/userservice/lockout"
+	"enchanted-micro/internal/userservice/mail"
+	"enchanted-micro/internal/userservice/mfa"
 	"enchanted-micro/internal/userservice/middleware"
+	"enchanted-micro/internal/userservice/notify"
+	"enchanted-micro/internal/userservice/sso"
+	"enchanted-micro/internal/userservice/tokens"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	// Config yükle
 	cfg := config.LoadConfig()
+	logger.Init("user-service", cfg.LogLevel, cfg.LogFormat)
+	if _, err := tracing.Init(context.Background(), "user-service", cfg.TraceExporter); err != nil {
+		logger.Fatal("tracing setup failed", "error", err)
+	}
 
 	// Database bağlantısı
 	database.ConnectDB(cfg)
 
 	// Gin router
-	r := gin.Default()
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	// Oturum ve giriş denemesi IP'leri ClientIP'den okunur; sahte
+	// X-Forwarded-For zincirlerine yalnızca gateway'den gelince güvenilir
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("invalid TRUSTED_PROXIES", "error", err)
+	}
+	r.Use(otelgin.Middleware("user-service"), requestid.Middleware(), logger.Middleware(), metrics.Middleware(), gin.Recovery())
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -35,19 +69,123 @@ func main() {
 		c.Next()
 	})
 
+	// İmza anahtarları; yeni anahtar devreye girmeden önce JWKS'te yayınlanır
+	signingKeys, err := keys.NewManager(context.Background(), database.DB, cfg)
+	if err != nil {
+		logger.Fatal("signing keys unavailable", "error", err)
+	}
+	go signingKeys.Run(context.Background(), time.Minute)
+
+	// Token issuer ve süresi dolmuş token temizliği
+	issuer := tokens.NewIssuer(database.DB, cfg, signingKeys)
+	go issuer.PurgeExpired(context.Background(), time.Hour)
+
+	// Çıkış yapılmış token'lar, diğer instance'ların çıkışları için periyodik senkronize edilir
+	revocations := revocation.NewCache(issuer.Revocations)
+	go revocations.Run(context.Background(), cfg.RevocationSyncInterval)
+
+	// Bildirimler e-posta ile gönderilir (MAIL_DRIVER)
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		logger.Fatal("mail setup failed", "error", err)
+	}
+	notifier := notify.NewEmail(mailer, cfg.AppURL, cfg.PasswordResetTTL, cfg.EmailVerificationTTL)
+
+	// Başarısız giriş sayaçları; süresi dolanlar periyodik silinir
+	guard := lockout.NewGuard(database.DB, cfg)
+	go guard.PurgeExpired(context.Background(), time.Hour)
+
+	// İki adımlı doğrulama (TOTP)
+	twoFactor := mfa.NewManager(database.DB, cfg)
+
+	// OpenID Connect sağlayıcılarıyla giriş
+	signOn := sso.NewManager(database.DB, cfg)
+
+	// Profil resimleri; yüklenen dosyalar buradan sunulur
+	avatars := avatar.NewStore(cfg.AvatarUploadPath)
+	r.Static(avatar.URLPrefix, cfg.AvatarUploadPath)
+	avatarHandler := handlers.NewAvatarHandler(cfg, avatars)
+
 	// User handler
-	userHandler := handlers.NewUserHandler(cfg)
+	userHandler := handlers.NewUserHandler(cfg, issuer, revocations, notifier, guard, twoFactor, signOn)
 
 	// Public routes
 	r.POST("/register", userHandler.Register)
 	r.POST("/login", userHandler.Login)
+	r.POST("/login/2fa", userHandler.LoginTwoFactor)
+	r.POST("/refresh", userHandler.Refresh)
+	r.GET("/oauth/providers", userHandler.OAuthProviders)
+	r.GET("/oauth/:provider/authorize", userHandler.OAuthAuthorize)
+	r.GET("/oauth/:provider/callback", userHandler.OAuthCallback)
+	r.POST("/oauth/token", userHandler.OAuthToken)
+	r.POST("/password/forgot", userHandler.ForgotPassword)
+	r.POST("/password/reset", userHandler.ResetPassword)
+	r.GET("/verify-email", userHandler.VerifyEmail)
+	r.POST("/verify-email", userHandler.VerifyEmail)
+	r.GET("/users", userHandler.GetPublicProfiles)
+	r.GET("/users/:id", userHandler.GetPublicProfile)
+	r.GET(jwks.Path, jwks.Handler(signingKeys.Published))
 
 	// Protected routes
 	protected := r.Group("/")
-	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.AuthMiddleware(cfg, signingKeys, revocations))
 	{
 		protected.GET("/profile", userHandler.GetProfile)
 		protected.PUT("/profile", userHandler.UpdateProfile)
+		protected.POST("/profile/avatar", avatarHandler.UploadAvatar)
+		protected.DELETE("/profile/avatar", avatarHandler.DeleteAvatar)
+		protected.PUT("/password", userHandler.ChangePassword)
+		protected.POST("/verify-email/resend", userHandler.ResendVerification)
+		protected.POST("/2fa/enroll", userHandler.EnrollTwoFactor)
+		protected.POST("/2fa/confirm", userHandler.ConfirmTwoFactor)
+		protected.POST("/2fa/disable", userHandler.DisableTwoFactor)
+		protected.POST("/2fa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+		protected.POST("/oauth/:provider/link", userHandler.OAuthLink)
+		protected.GET("/oauth/identities", userHandler.ListIdentities)
+		protected.POST("/oauth/identities", userHandler.LinkIdentity)
+		protected.DELETE("/oauth/identities/:id", userHandler.UnlinkIdentity)
+		protected.POST("/api-keys", userHandler.CreateAPIKey)
+		protected.GET("/api-keys", userHandler.ListAPIKeys)
+		protected.DELETE("/api-keys/:id", userHandler.RevokeAPIKey)
+		protected.GET("/sessions", userHandler.ListSessions)
+		protected.DELETE("/sessions/:id", userHandler.RevokeSession)
+		protected.POST("/logout", userHandler.Logout)
+		protected.POST("/logout-all", userHandler.LogoutAll)
+	}
+
+	// Hesap silme ve veri dışa aktarımı; bekleme süresi dolan hesaplar
+	// periyodik silinir
+	accounts := account.NewManager(database.DB, cfg, avatars)
+	go accounts.Run(context.Background(), time.Hour)
+	accountHandler := handlers.NewAccountHandler(issuer, revocations, twoFactor, accounts)
+	accountRoutes := r.Group("/account")
+	accountRoutes.Use(middleware.AuthMiddleware(cfg, signingKeys, revocations))
+	{
+		accountRoutes.DELETE("", accountHandler.DeleteAccount)
+		accountRoutes.POST("/restore", accountHandler.RestoreAccount)
+		accountRoutes.GET("/export", accountHandler.ExportAccount)
+	}
+
+	// Yönetici endpoint'leri
+	adminHandler := handlers.NewAdminHandler(issuer, revocations, guard, twoFactor)
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(cfg, signingKeys, revocations))
+	{
+		admin.GET("/users", identity.RequirePermission(identity.PermUsersRead), adminHandler.ListUsers)
+		admin.POST("/users/:id/disable", identity.RequirePermission(identity.PermUsersManage), adminHandler.DisableUser)
+		admin.POST("/users/:id/enable", identity.RequirePermission(identity.PermUsersManage), adminHandler.EnableUser)
+		admin.PUT("/users/:id/roles", identity.RequirePermission(identity.PermUsersManage), adminHandler.UpdateRoles)
+		admin.POST("/users/:id/unlock", identity.RequirePermission(identity.PermUsersManage), adminHandler.UnlockUser)
+		admin.DELETE("/users/:id/2fa", identity.RequirePermission(identity.PermUsersManage), adminHandler.ResetTwoFactor)
+		admin.GET("/audit-logs", identity.RequirePermission(identity.PermUsersRead), adminHandler.ListAuditLogs)
+	}
+
+	// Servisler arası endpoint'ler (INTERNAL_API_SECRET ile korunur)
+	internal := r.Group("/internal")
+	internal.Use(internalapi.Middleware(cfg.InternalAPISecret))
+	{
+		internal.GET("/revocations", revocation.Handler(issuer.Revocations))
+		internal.POST("/api-keys/verify", apikey.Handler(issuer.VerifyAPIKey))
 	}
 
 	// Health check
@@ -55,8 +193,17 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok", "service": "user-service"})
 	})
 
-	log.Printf("User Service %s portunda başlatılıyor...", cfg.Port)
+	// Liveness ve readiness
+	healthChecker := health.New("user-service")
+	healthChecker.Add("database", health.Database(database.GetDB))
+	healthChecker.Add("avatars", health.WritableDir(cfg.AvatarUploadPath))
+	healthChecker.Register(r)
+
+	// Prometheus metrikleri
+	metrics.Register(r)
+
+	slog.Info("server starting", "port", cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Server başlatılamadı:", err)
+		logger.Fatal("server failed", "error", err)
 	}
 }
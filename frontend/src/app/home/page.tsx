@@ -122,6 +122,7 @@ export default function HomePage() {
                   price={`₺${product.price.toFixed(2)}`}
                   image={product.image_url || ""}
                   category={product.category}
+                  seller={product.seller?.display_name}
                 />
               </motion.div>
             ))
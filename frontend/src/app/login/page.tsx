@@ -1,28 +1,54 @@
 'use client';
 
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import { useRouter } from 'next/navigation';
 import { motion } from 'framer-motion';
-import { Eye, EyeOff, User, Lock } from 'lucide-react';
-import userService, { LoginRequest } from '@/services/userService';
+import { Eye, EyeOff, User, Lock, KeyRound } from 'lucide-react';
+import userService, { LoginRequest, OAuthProvider } from '@/services/userService';
 
 export default function LoginPage() {
   const [formData, setFormData] = useState<LoginRequest>({
     username: '',
     password: '',
   });
+  const [mfaToken, setMfaToken] = useState('');
+  const [code, setCode] = useState('');
   const [showPassword, setShowPassword] = useState(false);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
+  const [providers, setProviders] = useState<OAuthProvider[]>([]);
   const router = useRouter();
 
+  useEffect(() => {
+    // Harici sağlayıcıyla girişte iki adımlı doğrulama kodu burada sorulur
+    const pending = sessionStorage.getItem('mfa_token');
+    if (pending) {
+      sessionStorage.removeItem('mfa_token');
+      setMfaToken(pending);
+    }
+    userService
+      .getOAuthProviders()
+      .then(setProviders)
+      .catch(() => setProviders([]));
+  }, []);
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setLoading(true);
     setError('');
 
     try {
-      await userService.login(formData);
+      if (mfaToken) {
+        await userService.loginTwoFactor(mfaToken, code);
+        router.push('/home');
+        return;
+      }
+      const result = await userService.login(formData);
+      if ('mfa_required' in result) {
+        // Şifre doğru, authenticator kodu bekleniyor
+        setMfaToken(result.mfa_token);
+        return;
+      }
       router.push('/home');
     } catch (err: any) {
       setError(err.message);
@@ -111,50 +137,75 @@ export default function LoginPage() {
 
             {/* Form */}
             <form onSubmit={handleSubmit} className="space-y-6">
-              {/* Username Input */}
-              <motion.div
-                initial={{ opacity: 0, x: -20 }}
-                animate={{ opacity: 1, x: 0 }}
-                transition={{ duration: 0.6, delay: 0.6 }}
-                className="relative"
-              >
-                <User className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
-                <input
-                  type="text"
-                  name="username"
-                  placeholder="Username"
-                  value={formData.username}
-                  onChange={handleInputChange}
-                  className="w-full pl-12 pr-4 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
-                  required
-                />
-              </motion.div>
-
-              {/* Password Input */}
-              <motion.div
-                initial={{ opacity: 0, x: -20 }}
-                animate={{ opacity: 1, x: 0 }}
-                transition={{ duration: 0.6, delay: 0.8 }}
-                className="relative"
-              >
-                <Lock className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
-                <input
-                  type={showPassword ? 'text' : 'password'}
-                  name="password"
-                  placeholder="Password"
-                  value={formData.password}
-                  onChange={handleInputChange}
-                  className="w-full pl-12 pr-12 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
-                  required
-                />
-                <button
-                  type="button"
-                  onClick={() => setShowPassword(!showPassword)}
-                  className="absolute right-3 top-1/2 transform -translate-y-1/2 text-cyan-300 hover:text-cyan-100 transition-colors"
+              {mfaToken ? (
+                /* İki adımlı doğrulama kodu */
+                <motion.div
+                  initial={{ opacity: 0, x: -20 }}
+                  animate={{ opacity: 1, x: 0 }}
+                  transition={{ duration: 0.6 }}
+                  className="relative"
                 >
-                  {showPassword ? <EyeOff className="w-5 h-5" /> : <Eye className="w-5 h-5" />}
-                </button>
-              </motion.div>
+                  <KeyRound className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
+                  <input
+                    type="text"
+                    name="code"
+                    placeholder="Doğrulama ya da kurtarma kodu"
+                    autoComplete="one-time-code"
+                    autoFocus
+                    value={code}
+                    onChange={(e) => setCode(e.target.value)}
+                    className="w-full pl-12 pr-4 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
+                    required
+                  />
+                </motion.div>
+              ) : (
+                <>
+                  {/* Username Input */}
+                  <motion.div
+                    initial={{ opacity: 0, x: -20 }}
+                    animate={{ opacity: 1, x: 0 }}
+                    transition={{ duration: 0.6, delay: 0.6 }}
+                    className="relative"
+                  >
+                    <User className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
+                    <input
+                      type="text"
+                      name="username"
+                      placeholder="Username"
+                      value={formData.username}
+                      onChange={handleInputChange}
+                      className="w-full pl-12 pr-4 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
+                      required
+                    />
+                  </motion.div>
+
+                  {/* Password Input */}
+                  <motion.div
+                    initial={{ opacity: 0, x: -20 }}
+                    animate={{ opacity: 1, x: 0 }}
+                    transition={{ duration: 0.6, delay: 0.8 }}
+                    className="relative"
+                  >
+                    <Lock className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
+                    <input
+                      type={showPassword ? 'text' : 'password'}
+                      name="password"
+                      placeholder="Password"
+                      value={formData.password}
+                      onChange={handleInputChange}
+                      className="w-full pl-12 pr-12 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
+                      required
+                    />
+                    <button
+                      type="button"
+                      onClick={() => setShowPassword(!showPassword)}
+                      className="absolute right-3 top-1/2 transform -translate-y-1/2 text-cyan-300 hover:text-cyan-100 transition-colors"
+                    >
+                      {showPassword ? <EyeOff className="w-5 h-5" /> : <Eye className="w-5 h-5" />}
+                    </button>
+                  </motion.div>
+                </>
+              )}
 
               {/* Error Message */}
               {error && (
@@ -176,10 +227,35 @@ export default function LoginPage() {
                 disabled={loading}
                 className="w-full py-3 bg-gradient-to-r from-cyan-500 to-blue-500 hover:from-cyan-600 hover:to-blue-600 text-white font-semibold rounded-xl transition-all duration-300 transform hover:scale-105 disabled:opacity-50 disabled:cursor-not-allowed shadow-lg"
               >
-                {loading ? 'Giriş Yapılıyor...' : 'Log in'}
+                {loading ? 'Giriş Yapılıyor...' : mfaToken ? 'Doğrula' : 'Log in'}
               </motion.button>
             </form>
 
+            {/* Harici sağlayıcılar */}
+            {!mfaToken && providers.length > 0 && (
+              <div className="mt-4 space-y-2">
+                {providers.map((provider) => (
+                  <a
+                    key={provider.name}
+                    href={userService.oauthAuthorizeUrl(provider.name)}
+                    className="block w-full py-2 text-center bg-white/10 hover:bg-white/20 border border-cyan-300/50 text-white rounded-xl transition-colors"
+                  >
+                    {provider.display_name} ile giriş yap
+                  </a>
+                ))}
+              </div>
+            )}
+
+            {/* Forgot Password Link */}
+            <div className="text-center mt-4">
+              <a
+                href="/reset-password"
+                className="text-cyan-300 hover:text-cyan-100 text-sm transition-colors"
+              >
+                Şifremi unuttum
+              </a>
+            </div>
+
             {/* Register Link */}
             <motion.div
               initial={{ opacity: 0 }}
@@ -0,0 +1,104 @@
+'use client';
+
+import { Suspense, useEffect, useRef, useState } from 'react';
+import { useRouter, useSearchParams } from 'next/navigation';
+import { motion } from 'framer-motion';
+import userService from '@/services/userService';
+
+// userservice'in döndüğü hata kodları
+const errorMessages: Record<string, string> = {
+  denied: 'Giriş sağlayıcıda iptal edildi',
+  invalid_state: 'Giriş oturumunun süresi dolmuş, tekrar deneyin',
+  email_required: 'Sağlayıcı e-posta adresinizi paylaşmadı',
+  account_exists: 'Bu e-posta ile bir hesap var; şifrenizle giriş yapıp hesabı profilinizden bağlayın',
+  disabled: 'Hesabınız devre dışı bırakılmış',
+  failed: 'Giriş yapılamadı, tekrar deneyin',
+};
+
+// Harici sağlayıcıyla giriş ve hesap bağlama bu sayfaya döner
+function OAuthCallback() {
+  const params = useSearchParams();
+  const router = useRouter();
+  const [message, setMessage] = useState('Giriş yapılıyor...');
+  const [error, setError] = useState('');
+  const started = useRef(false);
+
+  useEffect(() => {
+    // Kod tek kullanımlık; geliştirme modunda effect iki kez çalışmasın
+    if (started.current) return;
+    started.current = true;
+
+    const code = params.get('code');
+    const linkCode = params.get('link_code');
+    const failure = params.get('error');
+    if (failure) {
+      setError(errorMessages[failure] || errorMessages.failed);
+      return;
+    }
+    if (linkCode) {
+      // Bağlama, akışı başlatan kullanıcının oturumuyla tamamlanır
+      if (!userService.isAuthenticated()) {
+        setError('Hesap bağlamak için önce giriş yapın');
+        return;
+      }
+      setMessage('Hesap bağlanıyor...');
+      userService
+        .completeOAuthLink(linkCode)
+        .then((identity) => setMessage(`${identity.provider} hesabı bağlandı`))
+        .catch((err: any) => setError(err.message));
+      return;
+    }
+    if (!code) {
+      setError(errorMessages.failed);
+      return;
+    }
+    userService
+      .exchangeOAuthCode(code)
+      .then((result) => {
+        if ('mfa_required' in result) {
+          // Kod login sayfasında sorulur
+          sessionStorage.setItem('mfa_token', result.mfa_token);
+          router.replace('/login');
+          return;
+        }
+        router.replace('/home');
+      })
+      .catch((err: any) => setError(err.message));
+  }, [params, router]);
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 50 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ duration: 0.8, delay: 0.2 }}
+      className="relative z-10 bg-white/10 backdrop-blur-md rounded-2xl p-8 w-96 border border-cyan-400/30 shadow-2xl text-center"
+    >
+      <h1 className="text-3xl font-bold text-white mb-8 drop-shadow-lg">Giriş</h1>
+
+      {error ? (
+        <div className="text-red-300 text-sm bg-red-500/20 rounded-lg p-2">{error}</div>
+      ) : (
+        <div className="text-green-300 text-sm bg-green-500/20 rounded-lg p-2">{message}</div>
+      )}
+
+      <div className="mt-6">
+        <a
+          href={userService.isAuthenticated() ? '/home' : '/login'}
+          className="text-cyan-300 hover:text-cyan-100 font-semibold transition-colors"
+        >
+          Devam et
+        </a>
+      </div>
+    </motion.div>
+  );
+}
+
+export default function OAuthCallbackPage() {
+  return (
+    <div className="min-h-screen bg-gradient-to-b from-blue-900 via-blue-800 to-purple-900 flex items-center justify-center relative overflow-hidden">
+      <Suspense>
+        <OAuthCallback />
+      </Suspense>
+    </div>
+  );
+}
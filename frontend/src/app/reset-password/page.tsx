@@ -0,0 +1,123 @@
+'use client';
+
+import { Suspense, useState } from 'react';
+import { useRouter, useSearchParams } from 'next/navigation';
+import { motion } from 'framer-motion';
+import { Lock, Mail } from 'lucide-react';
+import userService from '@/services/userService';
+
+// Token yoksa sıfırlama bağlantısı istenir, e-postadaki bağlantıyla gelindiyse yeni şifre belirlenir
+function ResetPasswordForm() {
+  const token = useSearchParams().get('token');
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState('');
+  const [success, setSuccess] = useState('');
+  const router = useRouter();
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setLoading(true);
+    setError('');
+    setSuccess('');
+
+    try {
+      if (token) {
+        const result = await userService.resetPassword(token, password);
+        setSuccess(result.message + ' Giriş yapabilirsiniz.');
+        setTimeout(() => {
+          router.push('/login');
+        }, 2000);
+      } else {
+        const result = await userService.forgotPassword(email);
+        setSuccess(result.message);
+      }
+    } catch (err: any) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 50 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ duration: 0.8, delay: 0.2 }}
+      className="relative z-10 bg-white/10 backdrop-blur-md rounded-2xl p-8 w-96 border border-cyan-400/30 shadow-2xl"
+    >
+      <h1 className="text-3xl font-bold text-white text-center mb-8 drop-shadow-lg">
+        {token ? 'Yeni Şifre' : 'Şifremi Unuttum'}
+      </h1>
+
+      <form onSubmit={handleSubmit} className="space-y-6">
+        <div className="relative">
+          {token ? (
+            <>
+              <Lock className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
+              <input
+                type="password"
+                name="password"
+                placeholder="Yeni şifre"
+                minLength={6}
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                className="w-full pl-12 pr-4 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
+                required
+              />
+            </>
+          ) : (
+            <>
+              <Mail className="absolute left-3 top-1/2 transform -translate-y-1/2 text-cyan-300 w-5 h-5" />
+              <input
+                type="email"
+                name="email"
+                placeholder="Email"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                className="w-full pl-12 pr-4 py-3 bg-cyan-100/20 border border-cyan-300/50 rounded-xl text-white placeholder-cyan-200 focus:outline-none focus:ring-2 focus:ring-cyan-400 focus:border-transparent transition-all duration-300"
+                required
+              />
+            </>
+          )}
+        </div>
+
+        {error && (
+          <div className="text-red-300 text-sm text-center bg-red-500/20 rounded-lg p-2">
+            {error}
+          </div>
+        )}
+        {success && (
+          <div className="text-green-300 text-sm text-center bg-green-500/20 rounded-lg p-2">
+            {success}
+          </div>
+        )}
+
+        <button
+          type="submit"
+          disabled={loading}
+          className="w-full py-3 bg-gradient-to-r from-cyan-500 to-blue-500 hover:from-cyan-600 hover:to-blue-600 text-white font-semibold rounded-xl transition-all duration-300 transform hover:scale-105 disabled:opacity-50 disabled:cursor-not-allowed shadow-lg"
+        >
+          {loading ? 'Gönderiliyor...' : token ? 'Şifreyi Değiştir' : 'Bağlantı Gönder'}
+        </button>
+      </form>
+
+      <div className="text-center mt-6">
+        <a href="/login" className="text-cyan-300 hover:text-cyan-100 font-semibold transition-colors">
+          Girişe dön
+        </a>
+      </div>
+    </motion.div>
+  );
+}
+
+export default function ResetPasswordPage() {
+  return (
+    <div className="min-h-screen bg-gradient-to-b from-blue-900 via-blue-800 to-purple-900 flex items-center justify-center relative overflow-hidden">
+      <Suspense>
+        <ResetPasswordForm />
+      </Suspense>
+    </div>
+  );
+}
@@ -0,0 +1,65 @@
+'use client';
+
+import { Suspense, useEffect, useRef, useState } from 'react';
+import { useSearchParams } from 'next/navigation';
+import { motion } from 'framer-motion';
+import userService from '@/services/userService';
+
+// E-postadaki doğrulama bağlantısı bu sayfaya gelir
+function VerifyEmail() {
+  const token = useSearchParams().get('token');
+  const [message, setMessage] = useState('E-posta adresi doğrulanıyor...');
+  const [error, setError] = useState('');
+  const started = useRef(false);
+
+  useEffect(() => {
+    // Token tek kullanımlık; geliştirme modunda effect iki kez çalışmasın
+    if (started.current) return;
+    started.current = true;
+
+    if (!token) {
+      setError('Doğrulama bağlantısı geçersiz');
+      return;
+    }
+    userService
+      .verifyEmail(token)
+      .then((result) => setMessage(result.message || 'E-posta adresi doğrulandı'))
+      .catch((err: any) => setError(err.message));
+  }, [token]);
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 50 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ duration: 0.8, delay: 0.2 }}
+      className="relative z-10 bg-white/10 backdrop-blur-md rounded-2xl p-8 w-96 border border-cyan-400/30 shadow-2xl text-center"
+    >
+      <h1 className="text-3xl font-bold text-white mb-8 drop-shadow-lg">E-posta Doğrulama</h1>
+
+      {error ? (
+        <div className="text-red-300 text-sm bg-red-500/20 rounded-lg p-2">{error}</div>
+      ) : (
+        <div className="text-green-300 text-sm bg-green-500/20 rounded-lg p-2">{message}</div>
+      )}
+
+      <div className="mt-6">
+        <a
+          href={userService.isAuthenticated() ? '/home' : '/login'}
+          className="text-cyan-300 hover:text-cyan-100 font-semibold transition-colors"
+        >
+          Devam et
+        </a>
+      </div>
+    </motion.div>
+  );
+}
+
+export default function VerifyEmailPage() {
+  return (
+    <div className="min-h-screen bg-gradient-to-b from-blue-900 via-blue-800 to-purple-900 flex items-center justify-center relative overflow-hidden">
+      <Suspense>
+        <VerifyEmail />
+      </Suspense>
+    </div>
+  );
+}
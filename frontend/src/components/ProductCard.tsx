@@ -9,6 +9,7 @@ interface ProductCardProps {
   price?: string;
   image: string;
   category: string;
+  seller?: string;
   className?: string;
 }
 
@@ -18,6 +19,7 @@ export default function ProductCard({
   price, 
   image, 
   category,
+  seller,
   className = '' 
 }: ProductCardProps) {
   const getImageUrl = () => {
@@ -86,6 +88,12 @@ export default function ProductCard({
         <p className="text-gray-600 text-sm mb-3 line-clamp-3">
           {description}
         </p>
+
+        {seller && (
+          <p className="text-gray-500 text-xs mb-3">
+            Satıcı: {seller}
+          </p>
+        )}
         
         {price && (
           <div className="flex items-center justify-between">
@@ -1,5 +1,6 @@
 import axios from 'axios';
 import { API_BASE_URL } from '../config/config';
+import { attachAuthInterceptors } from './userService';
 
 const api = axios.create({
   baseURL: API_BASE_URL,
@@ -8,36 +9,25 @@ const api = axios.create({
   },
 });
 
-// Request interceptor - token'ı otomatik ekle
-api.interceptors.request.use(
-  (config) => {
-    const token = localStorage.getItem('token');
-    if (token) {
-      config.headers.Authorization = `Bearer ${token}`;
-    }
-    return config;
-  },
-  (error) => {
-    return Promise.reject(error);
-  }
-);
-
-// Response interceptor - 401 durumunda token'ı temizle
-api.interceptors.response.use(
-  (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('user');
-      window.location.href = '/login';
-    }
-    return Promise.reject(error);
-  }
-);
+// Token ekleme ve 401'de token yenileme
+attachAuthInterceptors(api);
+
+// Satıcının herkese açık profili
+export interface Seller {
+  id: number;
+  username: string;
+  display_name: string;
+  avatar_url: string;
+  avatar_urls?: Record<'small' | 'medium' | 'large', string>;
+  location: string;
+  joined_at: string;
+}
 
 export interface Product {
   id: number;
   user_id: number;
+  // Sadece include=seller ile istendiğinde; satıcı bulunamazsa yok
+  seller?: Seller;
   title: string;
   description: string;
   price: number;
@@ -69,10 +59,10 @@ export interface ApiResponse<T> {
 }
 
 class ProductService {
-  // Tüm ürünleri getir
+  // Tüm ürünleri satıcılarıyla getir
   async getProducts(): Promise<{ products: Product[] }> {
     try {
-      const response = await api.get('/products');
+      const response = await api.get('/products', { params: { include: 'seller' } });
       return response.data;
     } catch (error: any) {
       throw new Error(error.response?.data?.error || 'Ürünler alınamadı');
@@ -1,4 +1,4 @@
-import axios from 'axios';
+import axios, { AxiosInstance, InternalAxiosRequestConfig } from 'axios';
 import { API_BASE_URL } from '../config/config';
 
 const api = axios.create({
@@ -8,41 +8,133 @@ const api = axios.create({
   },
 });
 
-// Request interceptor - token'ı otomatik ekle
-api.interceptors.request.use(
-  (config) => {
-    const token = localStorage.getItem('token');
-    if (token) {
-      config.headers.Authorization = `Bearer ${token}`;
+// Aynı anda gelen 401'ler tek bir yenileme isteğini bekler
+let refreshPromise: Promise<string> | null = null;
+
+// Refresh token ile yeni access token al. Başka bir sekme token'ı az önce
+// yenilediyse localStorage'daki yeni token kullanılır.
+export function refreshAccessToken(): Promise<string> {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshPromise = axios
+      .post(`${API_BASE_URL}/user/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        storeTokens(response.data);
+        return response.data.token as string;
+      })
+      .catch((error) => {
+        const current = localStorage.getItem('refresh_token');
+        if (current && current !== refreshToken) {
+          return localStorage.getItem('token') as string;
+        }
+        throw error;
+      })
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+}
+
+function storeTokens(data: TokenResponse): void {
+  localStorage.setItem('token', data.token);
+  localStorage.setItem('refresh_token', data.refresh_token);
+}
+
+function clearSession(): void {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('user');
+}
+
+type RetriableRequest = InternalAxiosRequestConfig & { _retried?: boolean };
+
+// Token ekleyen ve 401 durumunda token'ı bir kez yenileyip isteği tekrarlayan
+// interceptor'lar. Yenileme de başarısız olursa oturum kapatılır.
+export function attachAuthInterceptors(instance: AxiosInstance): void {
+  instance.interceptors.request.use(
+    (config) => {
+      const token = localStorage.getItem('token');
+      if (token) {
+        config.headers.Authorization = `Bearer ${token}`;
+      }
+      return config;
+    },
+    (error) => {
+      return Promise.reject(error);
     }
-    return config;
-  },
-  (error) => {
-    return Promise.reject(error);
-  }
-);
-
-// Response interceptor - 401 durumunda token'ı temizle
-api.interceptors.response.use(
-  (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('user');
+  );
+
+  instance.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+      const original = error.config as RetriableRequest | undefined;
+      if (error.response?.status !== 401 || !original) {
+        return Promise.reject(error);
+      }
+
+      if (!original._retried && localStorage.getItem('refresh_token')) {
+        original._retried = true;
+        try {
+          const token = await refreshAccessToken();
+          original.headers.Authorization = `Bearer ${token}`;
+          return instance(original);
+        } catch {
+          // Aşağıda oturum kapatılır
+        }
+      }
+
+      clearSession();
       window.location.href = '/login';
+      return Promise.reject(error);
     }
-    return Promise.reject(error);
-  }
-);
+  );
+}
+
+attachAuthInterceptors(api);
+
+export type AvatarSize = 'small' | 'medium' | 'large';
 
 export interface User {
   id: number;
   username: string;
   email: string;
+  email_verified_at?: string;
+  display_name: string;
+  bio: string;
+  avatar_url: string;
+  // Boyut adına göre: small (64px), medium (128px), large (256px)
+  avatar_urls?: Record<AvatarSize, string>;
+  location: string;
+  roles: string[];
+  permissions?: string[];
+  two_factor_enabled: boolean;
+  // Hesap silinmek üzere işaretlendiyse silineceği zaman
+  deletion_scheduled_at?: string;
   created_at: string;
   updated_at: string;
 }
 
+// Diğer kullanıcılara gösterilen profil
+export interface PublicProfile {
+  id: number;
+  username: string;
+  display_name: string;
+  bio: string;
+  avatar_url: string;
+  // Boyut adına göre: small (64px), medium (128px), large (256px)
+  avatar_urls?: Record<AvatarSize, string>;
+  location: string;
+  joined_at: string;
+}
+
+export interface UpdateProfileRequest {
+  email?: string;
+  display_name?: string;
+  bio?: string;
+  location?: string;
+}
+
 export interface CreateUserRequest {
   username: string;
   password: string;
@@ -54,11 +146,69 @@ export interface LoginRequest {
   password: string;
 }
 
-export interface LoginResponse {
+export interface TokenResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
+}
+
+export interface LoginResponse extends TokenResponse {
   user: User;
 }
 
+// İki adımlı doğrulama açık hesaplarda login token yerine bunu döner
+export interface MFAChallengeResponse {
+  mfa_required: true;
+  mfa_token: string;
+  expires_in: number;
+}
+
+export interface TwoFactorEnrollment {
+  secret: string;
+  otpauth_uri: string;
+}
+
+export type ApiKeyScope = 'products:read' | 'products:write';
+
+export interface ApiKey {
+  id: number;
+  name: string;
+  prefix: string;
+  scopes: ApiKeyScope[];
+  expires_at: string;
+  last_used_at?: string;
+  created_at: string;
+}
+
+// Anahtarın kendisi sadece oluşturulurken döner
+export interface CreatedApiKey extends ApiKey {
+  key: string;
+}
+
+// Giriş yapılmış cihaz; current isteği yapan oturumdur
+export interface Session {
+  id: string;
+  user_agent: string;
+  ip: string;
+  last_seen_at: string;
+  expires_at: string;
+  created_at: string;
+  current: boolean;
+}
+
+export interface OAuthProvider {
+  name: string;
+  display_name: string;
+}
+
+export interface LinkedIdentity {
+  id: number;
+  provider: string;
+  email: string;
+  last_login_at?: string;
+  created_at: string;
+}
+
 export interface ApiResponse<T> {
   message?: string;
   user?: T;
@@ -77,14 +227,16 @@ class UserService {
   }
 
   // Kullanıcı girişi
-  async login(data: LoginRequest): Promise<LoginResponse> {
+  async login(data: LoginRequest): Promise<LoginResponse | MFAChallengeResponse> {
     try {
       const response = await api.post('/user/login', data);
-      const { token, user } = response.data;
-      
+      if (response.data.mfa_required) {
+        return response.data;
+      }
+
       // Token ve user bilgilerini localStorage'a kaydet
-      localStorage.setItem('token', token);
-      localStorage.setItem('user', JSON.stringify(user));
+      storeTokens(response.data);
+      localStorage.setItem('user', JSON.stringify(response.data.user));
       
       return response.data;
     } catch (error: any) {
@@ -92,6 +244,213 @@ class UserService {
     }
   }
 
+  // Girişin ikinci adımı: authenticator ya da kurtarma kodu. Yanlış kodda
+  // login sayfasına yönlendirilmemesi için interceptor'sız istek atılır.
+  async loginTwoFactor(mfaToken: string, code: string): Promise<LoginResponse> {
+    try {
+      const response = await axios.post(`${API_BASE_URL}/user/login/2fa`, { mfa_token: mfaToken, code });
+      storeTokens(response.data);
+      localStorage.setItem('user', JSON.stringify(response.data.user));
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Giriş yaparken hata oluştu');
+    }
+  }
+
+  // İki adımlı doğrulama kurulumunu başlat
+  async enrollTwoFactor(): Promise<TwoFactorEnrollment> {
+    try {
+      const response = await api.post('/user/2fa/enroll');
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'İki adımlı doğrulama başlatılamadı');
+    }
+  }
+
+  // Kurulumu kodla onayla; kurtarma kodları sadece burada döner
+  async confirmTwoFactor(code: string): Promise<{ recovery_codes: string[] }> {
+    try {
+      const response = await api.post('/user/2fa/confirm', { code });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'İki adımlı doğrulama açılamadı');
+    }
+  }
+
+  // İki adımlı doğrulamayı kapat
+  async disableTwoFactor(password: string, code: string): Promise<{ message: string }> {
+    try {
+      const response = await api.post('/user/2fa/disable', { password, code });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'İki adımlı doğrulama kapatılamadı');
+    }
+  }
+
+  // Yeni kurtarma kodları oluştur; eskiler geçersiz olur
+  async regenerateRecoveryCodes(code: string): Promise<{ recovery_codes: string[] }> {
+    try {
+      const response = await api.post('/user/2fa/recovery-codes', { code });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Kurtarma kodları oluşturulamadı');
+    }
+  }
+
+  // Script'ler için API anahtarı oluştur
+  async createApiKey(data: { name: string; scopes: ApiKeyScope[]; expires_at?: string }): Promise<CreatedApiKey> {
+    try {
+      const response = await api.post('/user/api-keys', data);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'API anahtarı oluşturulamadı');
+    }
+  }
+
+  // Aktif API anahtarlarını listele
+  async listApiKeys(): Promise<ApiKey[]> {
+    try {
+      const response = await api.get('/user/api-keys');
+      return response.data.api_keys;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'API anahtarları alınamadı');
+    }
+  }
+
+  // API anahtarını iptal et
+  async revokeApiKey(id: number): Promise<{ message: string }> {
+    try {
+      const response = await api.delete(`/user/api-keys/${id}`);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'API anahtarı iptal edilemedi');
+    }
+  }
+
+  // Giriş yapılmış cihazları listele
+  async listSessions(): Promise<Session[]> {
+    try {
+      const response = await api.get('/user/sessions');
+      return response.data.sessions;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Oturumlar alınamadı');
+    }
+  }
+
+  // Bir cihazdaki oturumu sonlandır
+  async revokeSession(id: string): Promise<{ message: string }> {
+    try {
+      const response = await api.delete(`/user/sessions/${encodeURIComponent(id)}`);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Oturum sonlandırılamadı');
+    }
+  }
+
+  // Giriş sayfasında gösterilecek harici sağlayıcılar
+  async getOAuthProviders(): Promise<OAuthProvider[]> {
+    try {
+      const response = await api.get('/user/oauth/providers');
+      return response.data.providers;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Giriş sağlayıcıları alınamadı');
+    }
+  }
+
+  // Sağlayıcıyla girişi başlatan adres; tarayıcı doğrudan bu adrese gider
+  oauthAuthorizeUrl(provider: string): string {
+    return `${API_BASE_URL}/user/oauth/${encodeURIComponent(provider)}/authorize`;
+  }
+
+  // Callback'ten gelen tek kullanımlık kodu token'a çevir; iki adımlı
+  // doğrulama açıksa login gibi challenge döner
+  async exchangeOAuthCode(code: string): Promise<LoginResponse | MFAChallengeResponse> {
+    try {
+      const response = await axios.post(`${API_BASE_URL}/user/oauth/token`, { code });
+      if (response.data.mfa_required) {
+        return response.data;
+      }
+
+      storeTokens(response.data);
+      localStorage.setItem('user', JSON.stringify(response.data.user));
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Giriş yaparken hata oluştu');
+    }
+  }
+
+  // Hesaba sağlayıcı bağla; dönen adrese tarayıcıyla gidilmeli
+  async linkOAuthProvider(provider: string): Promise<string> {
+    try {
+      const response = await api.post(`/user/oauth/${encodeURIComponent(provider)}/link`);
+      return response.data.authorization_url;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Hesap bağlama başlatılamadı');
+    }
+  }
+
+  // Bağlı harici hesapları listele
+  // Callback'ten gelen bağlama kodunu oturum açmış kullanıcının hesabına
+  // uygula; kod yalnızca bağlamayı başlatan kullanıcı için geçerli
+  async completeOAuthLink(code: string): Promise<LinkedIdentity> {
+    try {
+      const response = await api.post('/user/oauth/identities', { code });
+      return response.data.identity;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Hesap bağlanamadı');
+    }
+  }
+
+  async listIdentities(): Promise<LinkedIdentity[]> {
+    try {
+      const response = await api.get('/user/oauth/identities');
+      return response.data.identities;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Bağlı hesaplar alınamadı');
+    }
+  }
+
+  // Bağlı harici hesabı kaldır
+  async unlinkIdentity(id: number): Promise<{ message: string }> {
+    try {
+      const response = await api.delete(`/user/oauth/identities/${id}`);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Hesap bağlantısı kaldırılamadı');
+    }
+  }
+
+  // Hesabı silinmek üzere işaretle; bekleme süresi içinde geri alınabilir
+  async deleteAccount(password: string, code?: string): Promise<{ message: string; deletion_scheduled_at: string }> {
+    try {
+      const response = await api.delete('/user/account', { data: { password, code } });
+      clearSession();
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Hesap silinemedi');
+    }
+  }
+
+  // Silinmek üzere işaretlenmiş hesabı geri al
+  async restoreAccount(): Promise<{ message: string }> {
+    try {
+      const response = await api.post('/user/account/restore');
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Hesap geri alınamadı');
+    }
+  }
+
+  // Hesap verilerini ve ilanları ZIP olarak indir
+  async exportAccount(): Promise<Blob> {
+    try {
+      const response = await api.get('/user/account/export', { responseType: 'blob' });
+      return response.data;
+    } catch (error: any) {
+      throw new Error('Verileriniz dışa aktarılamadı');
+    }
+  }
+
   // Kullanıcı profili getir
   async getProfile(): Promise<{ user: User }> {
     try {
@@ -103,7 +462,7 @@ class UserService {
   }
 
   // Profil güncelle
-  async updateProfile(data: { email: string }): Promise<ApiResponse<User>> {
+  async updateProfile(data: UpdateProfileRequest): Promise<ApiResponse<User>> {
     try {
       const response = await api.put('/user/profile', data);
       return response.data;
@@ -112,10 +471,118 @@ class UserService {
     }
   }
 
-  // Çıkış yap
+  // Avatar yükle; resim kare kırpılıp farklı boyutlarda kaydedilir
+  async uploadAvatar(file: File): Promise<{ avatar_url: string; avatar_urls: Record<AvatarSize, string> }> {
+    try {
+      const formData = new FormData();
+      formData.append('avatar', file);
+      const response = await api.post('/user/profile/avatar', formData, {
+        headers: { 'Content-Type': 'multipart/form-data' },
+      });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Avatar yüklenemedi');
+    }
+  }
+
+  // Avatarı kaldır
+  async deleteAvatar(): Promise<ApiResponse<void>> {
+    try {
+      const response = await api.delete('/user/profile/avatar');
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Avatar kaldırılamadı');
+    }
+  }
+
+  // Herkese açık profil
+  async getPublicProfile(id: number): Promise<{ user: PublicProfile }> {
+    try {
+      const response = await api.get(`/user/users/${id}`);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Profil bulunamadı');
+    }
+  }
+
+  // Şifre değiştir - diğer cihazlardaki oturumlar kapanır
+  async changePassword(data: { current_password: string; new_password: string }): Promise<LoginResponse> {
+    try {
+      const response = await api.put('/user/password', data);
+      storeTokens(response.data);
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Şifre değiştirilirken hata oluştu');
+    }
+  }
+
+  // Şifre sıfırlama bağlantısı iste
+  async forgotPassword(email: string): Promise<{ message: string }> {
+    try {
+      const response = await api.post('/user/password/forgot', { email });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Şifre sıfırlama isteği gönderilemedi');
+    }
+  }
+
+  // E-postadaki token ile yeni şifre belirle
+  async resetPassword(token: string, newPassword: string): Promise<{ message: string }> {
+    try {
+      const response = await api.post('/user/password/reset', { token, new_password: newPassword });
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Şifre sıfırlanırken hata oluştu');
+    }
+  }
+
+  // E-postadaki token ile adresi doğrula; oturum açıksa token'daki claim güncellenir
+  async verifyEmail(token: string): Promise<ApiResponse<User>> {
+    try {
+      const response = await api.post('/user/verify-email', { token });
+      if (this.isAuthenticated()) {
+        localStorage.setItem('user', JSON.stringify(response.data.user));
+        await refreshAccessToken().catch(() => undefined);
+      }
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'E-posta doğrulanırken hata oluştu');
+    }
+  }
+
+  // Doğrulama e-postasını tekrar gönder
+  async resendVerification(): Promise<{ message: string }> {
+    try {
+      const response = await api.post('/user/verify-email/resend');
+      return response.data;
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Doğrulama e-postası gönderilemedi');
+    }
+  }
+
+  // Çıkış yap - token'lar sunucuda da iptal edilir
   logout(): void {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+    const refreshToken = localStorage.getItem('refresh_token');
+    const request = localStorage.getItem('token')
+      ? api.post('/user/logout', { refresh_token: refreshToken ?? '' })
+      : Promise.resolve();
+
+    request
+      .catch(() => undefined)
+      .finally(() => {
+        clearSession();
+        window.location.href = '/login';
+      });
+  }
+
+  // Tüm cihazlardan çıkış yap
+  async logoutAll(): Promise<void> {
+    try {
+      await api.post('/user/logout-all');
+    } catch (error: any) {
+      throw new Error(error.response?.data?.error || 'Çıkış yapılırken hata oluştu');
+    }
+    clearSession();
     window.location.href = '/login';
   }
 
@@ -1,87 +1,89 @@
 package main
 
 import (
-	"fmt"
-	"io"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/gin-gonic/gin"
-)
+	"enchanted-micro/internal/gateway/config"
+	"enchanted-micro/internal/gateway/proxy"
+	"enchanted-micro/internal/gateway/ratelimit"
+	"enchanted-micro/internal/gateway/router"
+	"enchanted-micro/internal/pkg/health"
+	"enchanted-micro/internal/pkg/jwks"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/requestid"
+	"enchanted-micro/internal/pkg/tracing"
 
-// Service URLs
-const (
-	UserServiceURL    = "http://localhost:8080"
-	ProductServiceURL = "http://localhost:8081"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// ProxyRequest proxies a request to the target service
-func ProxyRequest(c *gin.Context, targetURL string) {
-	// Create the full URL
-	fullURL := targetURL + c.Request.URL.Path
-	if c.Request.URL.RawQuery != "" {
-		fullURL += "?" + c.Request.URL.RawQuery
-	}
-
-	// Create request body
-	var body io.Reader
-	if c.Request.Body != nil {
-		body = c.Request.Body
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
+	logger.Init("gin-gateway", cfg.LogLevel, cfg.LogFormat)
+	if _, err := tracing.Init(context.Background(), "gin-gateway", cfg.TraceExporter); err != nil {
+		logger.Fatal("tracing setup failed", "error", err)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest(c.Request.Method, fullURL, body)
+	// Load route table
+	table, err := router.LoadTable(cfg.RoutesFile)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create request"})
-		return
+		logger.Fatal("failed to load route table", "path", cfg.RoutesFile, "error", err)
 	}
-
-	// Copy headers
-	for key, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
+	// Rate limit store
+	var limiter ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "redis" {
+		limiter = ratelimit.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), "gateway:ratelimit:")
 	}
 
-	// Make the request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to reach service"})
-		return
-	}
-	defer resp.Body.Close()
+	// Token verification keys are fetched lazily from the user service's JWKS
+	keys := jwks.NewClient(cfg.JWKSURL, &http.Client{Timeout: 5 * time.Second})
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read response"})
-		return
-	}
+	transport := proxy.NewTransport(cfg)
+	gw := router.New(cfg, table, proxy.New(transport), &http.Client{Transport: transport}, limiter, keys)
 
-	// Set response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
-	}
+	// Hot-reload the route table on file change and on SIGHUP
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gw.WatchFile(ctx, cfg.RoutesFile, cfg.RoutesReloadInterval)
 
-	// Return response
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), bodyBytes)
-}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			gw.ReloadFile(cfg.RoutesFile)
+		}
+	}()
 
-func main() {
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
-	r := gin.Default()
+	r := gin.New()
+	// ClientIP keys anonymous rate limits and is forwarded upstream; only
+	// X-Forwarded-For from configured proxies is believed
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("invalid GATEWAY_TRUSTED_PROXIES", "error", err)
+	}
+	r.Use(otelgin.Middleware("gin-gateway"), requestid.Middleware(), logger.Middleware(), metrics.Middleware(), gin.Recovery())
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin,Content-Type,Accept,Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -94,64 +96,39 @@ func main() {
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": "gin-gateway",
-			"port":    "8090",
+			"status":    "ok",
+			"service":   "gin-gateway",
+			"port":      cfg.Port,
+			"upstreams": gw.Status(),
 		})
 	})
 
-	// User Service Routes
-	r.Any("/user/*path", func(c *gin.Context) {
-		// Remove /user prefix and proxy to user service
-		path := c.Param("path")
-		if path == "" {
-			path = "/"
-		}
-		c.Request.URL.Path = path
-		ProxyRequest(c, UserServiceURL)
-	})
-
-	// Product Service Routes - wildcard first (more specific)
-	r.Any("/products/*path", func(c *gin.Context) {
-		// Get the wildcard part (e.g., "/2" from "/products/2")
-		path := c.Param("path")
-		if path == "" {
-			path = "/"
-		}
-		// Reconstruct the full path for the product service
-		originalPath := c.Request.URL.Path
-		c.Request.URL.Path = "/products" + path
-		fmt.Printf("DEBUG: Original path: %s, Wildcard path: %s, New path: %s\n", originalPath, path, c.Request.URL.Path)
-		ProxyRequest(c, ProductServiceURL)
-	})
-
-	// Exact match for /products (less specific, after wildcard)
-	r.Any("/products", func(c *gin.Context) {
-		ProxyRequest(c, ProductServiceURL)
-	})
-
-	// Direct product routes (without /products prefix)
-	r.Any("/my-products", func(c *gin.Context) {
-		c.Request.URL.Path = "/my-products"
-		ProxyRequest(c, ProductServiceURL)
-	})
+	// Liveness and readiness. Upstream pools are reported on /health only;
+	// an upstream with an open breaker must not make the gateway unready.
+	healthChecker := health.New("gin-gateway")
+	if cfg.VerifyTokens {
+		healthChecker.Add("jwks", keys.Ready)
+	}
+	healthChecker.Register(r)
 
-	// Upload routes
-	r.Any("/uploads/*path", func(c *gin.Context) {
-		ProxyRequest(c, ProductServiceURL)
-	})
+	// Prometheus metrics
+	metrics.Register(r)
 
 	// Root route
 	r.GET("/", func(c *gin.Context) {
+		services := gin.H{}
+		for name, upstream := range gw.Table().Upstreams {
+			services[name] = upstream.Instances
+		}
+
 		c.JSON(200, gin.H{
-			"message": "Gin API Gateway",
-			"version": "1.0.0",
-			"services": gin.H{
-				"user":    UserServiceURL,
-				"product": ProductServiceURL,
-			},
+			"message":  "Gin API Gateway",
+			"version":  "1.0.0",
+			"services": services,
 			"endpoints": gin.H{
 				"health":        "GET /health",
+				"health_live":   "GET /health/live",
+				"health_ready":  "GET /health/ready",
 				"user_register": "POST /user/register",
 				"user_login":    "POST /user/login",
 				"user_profile":  "GET /user/profile",
@@ -161,11 +138,15 @@ func main() {
 		})
 	})
 
-	log.Println("🚀 Gin API Gateway starting on port 8090...")
-	log.Printf("📡 User Service: %s", UserServiceURL)
-	log.Printf("📦 Product Service: %s", ProductServiceURL)
-	
-	if err := r.Run(":8090"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Everything else is dispatched through the route table
+	r.NoRoute(gw.Handle)
+
+	slog.Info("gateway starting", "port", cfg.Port)
+	for name, upstream := range table.Upstreams {
+		slog.Info("upstream configured", "upstream", name, "balancer", upstream.Balancer, "instances", upstream.Instances)
+	}
+
+	if err := r.Run(":" + cfg.Port); err != nil {
+		logger.Fatal("server failed", "error", err)
 	}
 }
@@ -0,0 +1,196 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker rejects requests
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Settings control when a breaker trips and how it recovers
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing again
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of trial requests allowed while half-open;
+	// that many must succeed to close the breaker again
+	HalfOpenRequests int
+}
+
+// DefaultSettings are used for upstreams without explicit breaker settings
+var DefaultSettings = Settings{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+	HalfOpenRequests: 1,
+}
+
+// Breaker is a consecutive-failure circuit breaker
+type Breaker struct {
+	mu       sync.Mutex
+	settings Settings
+	now      func() time.Time
+
+	state     State
+	failures  int
+	openedAt  time.Time
+	inFlight  int
+	successes int
+}
+
+// WithDefaults fills unset fields from DefaultSettings
+func (s Settings) WithDefaults() Settings {
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = DefaultSettings.FailureThreshold
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = DefaultSettings.OpenTimeout
+	}
+	if s.HalfOpenRequests <= 0 {
+		s.HalfOpenRequests = DefaultSettings.HalfOpenRequests
+	}
+	return s
+}
+
+func New(settings Settings) *Breaker {
+	return &Breaker{settings: settings.WithDefaults(), now: time.Now}
+}
+
+// Settings returns the settings the breaker was created with
+func (b *Breaker) Settings() Settings {
+	return b.settings
+}
+
+// Allow reserves a slot for one request. The returned function must be called
+// exactly once with the outcome of the request.
+func (b *Breaker) Allow() (func(success bool), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.effectiveState() == HalfOpen {
+		b.state = HalfOpen
+		b.inFlight = 0
+		b.successes = 0
+	}
+
+	switch b.state {
+	case Open:
+		return nil, ErrOpen
+	case HalfOpen:
+		if b.inFlight >= b.settings.HalfOpenRequests {
+			return nil, ErrOpen
+		}
+		b.inFlight++
+	}
+
+	halfOpen := b.state == HalfOpen
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.record(halfOpen, success) })
+	}, nil
+}
+
+func (b *Breaker) record(halfOpen, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if halfOpen && b.state == HalfOpen {
+		b.inFlight--
+		if !success {
+			b.trip()
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenRequests {
+			b.state = Closed
+			b.failures = 0
+		}
+		return
+	}
+
+	if b.state != Closed {
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.settings.FailureThreshold {
+		b.trip()
+	}
+}
+
+// effectiveState is the state the next request sees: an open breaker whose
+// timeout elapsed is half-open even before a request moves it there
+func (b *Breaker) effectiveState() State {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.inFlight = 0
+	b.successes = 0
+}
+
+// RetryAfter is how long callers should wait before the breaker lets a
+// request through again; zero when it is not open.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retryAfter()
+}
+
+func (b *Breaker) retryAfter() time.Duration {
+	if b.state != Open {
+		return 0
+	}
+	remaining := b.settings.OpenTimeout - b.now().Sub(b.openedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Snapshot describes the breaker for health output
+type Snapshot struct {
+	State      string `json:"state"`
+	Failures   int    `json:"consecutive_failures"`
+	RetryAfter string `json:"retry_after,omitempty"`
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Snapshot{State: b.effectiveState().String(), Failures: b.failures}
+	if d := b.retryAfter(); d > 0 {
+		s.RetryAfter = d.Round(time.Second).String()
+	}
+	return s
+}
@@ -0,0 +1,192 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for breakers under test
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestBreaker(s Settings) (*Breaker, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	b := New(s)
+	b.now = c.now
+	return b, c
+}
+
+// fail records n failed requests
+func fail(t *testing.T, b *Breaker, n int) {
+	t.Helper()
+	for range n {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		done(false)
+	}
+}
+
+func TestSnapshotReportsEffectiveState(t *testing.T) {
+	b, c := newTestBreaker(Settings{FailureThreshold: 2, OpenTimeout: 10 * time.Second})
+	fail(t, b, 2)
+
+	tests := []struct {
+		elapsed    time.Duration
+		state      string
+		retryAfter string
+	}{
+		{0, "open", "10s"},
+		{9 * time.Second, "open", "1s"},
+		// Nothing called Allow yet, but the next request would be a probe
+		{10 * time.Second, "half-open", ""},
+		{time.Hour, "half-open", ""},
+	}
+	opened := c.t
+	for _, tt := range tests {
+		c.t = opened.Add(tt.elapsed)
+		s := b.Snapshot()
+		if s.State != tt.state || s.RetryAfter != tt.retryAfter || s.Failures != 2 {
+			t.Errorf("after %s: snapshot %+v, want state %s retry after %q", tt.elapsed, s, tt.state, tt.retryAfter)
+		}
+	}
+}
+
+// step is one action against a breaker under test: "ok" and "fail" run a
+// request to completion, "hold" starts one and keeps it in flight,
+// "release-ok" and "release-fail" finish the oldest held request and
+// "reject" expects Allow to refuse. state is checked afterwards; the zero
+// value is Closed.
+type step struct {
+	advance time.Duration
+	op      string
+	state   State
+}
+
+func TestTransitions(t *testing.T) {
+	settings := Settings{FailureThreshold: 3, OpenTimeout: 10 * time.Second, HalfOpenRequests: 2}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "trips after consecutive failures",
+			steps: []step{
+				{op: "fail", state: Closed},
+				{op: "fail", state: Closed},
+				{op: "fail", state: Open},
+				{op: "reject", state: Open},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{op: "fail", state: Closed},
+				{op: "fail", state: Closed},
+				{op: "ok", state: Closed},
+				{op: "fail", state: Closed},
+				{op: "fail", state: Closed},
+				{op: "fail", state: Open},
+			},
+		},
+		{
+			name: "stays open until the timeout",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail", state: Open},
+				{advance: 9 * time.Second, op: "reject", state: Open},
+				{advance: time.Second, op: "hold", state: HalfOpen},
+			},
+		},
+		{
+			name: "closes after enough half-open successes",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail", state: Open},
+				{advance: 10 * time.Second, op: "ok", state: HalfOpen},
+				{op: "ok", state: Closed},
+				{op: "fail", state: Closed},
+			},
+		},
+		{
+			name: "half-open failure reopens",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail", state: Open},
+				{advance: 10 * time.Second, op: "ok", state: HalfOpen},
+				{op: "fail", state: Open},
+				{op: "reject", state: Open},
+				{advance: 10 * time.Second, op: "hold", state: HalfOpen},
+			},
+		},
+		{
+			name: "half-open admits only HalfOpenRequests at a time",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail", state: Open},
+				{advance: 10 * time.Second, op: "hold", state: HalfOpen},
+				{op: "hold", state: HalfOpen},
+				{op: "reject", state: HalfOpen},
+				// A finished probe frees its slot
+				{op: "release-ok", state: HalfOpen},
+				{op: "hold", state: HalfOpen},
+				{op: "reject", state: HalfOpen},
+				{op: "release-ok", state: Closed},
+			},
+		},
+		{
+			name: "requests started before the breaker opened are ignored",
+			steps: []step{
+				{op: "hold", state: Closed},
+				{op: "fail"}, {op: "fail"}, {op: "fail", state: Open},
+				{op: "release-ok", state: Open},
+				{advance: 10 * time.Second, op: "hold", state: HalfOpen},
+				{op: "hold", state: HalfOpen},
+				{op: "reject", state: HalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, c := newTestBreaker(settings)
+			var held []func(bool)
+			for i, s := range tt.steps {
+				c.t = c.t.Add(s.advance)
+				switch s.op {
+				case "ok", "fail", "hold":
+					done, err := b.Allow()
+					if err != nil {
+						t.Fatalf("step %d: Allow: %v", i, err)
+					}
+					if s.op == "hold" {
+						held = append(held, done)
+					} else {
+						done(s.op == "ok")
+					}
+				case "release-ok", "release-fail":
+					held[0](s.op == "release-ok")
+					held = held[1:]
+				case "reject":
+					if _, err := b.Allow(); err != ErrOpen {
+						t.Fatalf("step %d: Allow returned %v, want ErrOpen", i, err)
+					}
+				}
+				if got := b.effectiveState(); got != s.state {
+					t.Fatalf("step %d (%s): state %s, want %s", i, s.op, got, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestDoneCountsOnce(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 2})
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(false)
+	done(false)
+	if s := b.Snapshot(); s.State != "closed" || s.Failures != 1 {
+		t.Fatalf("snapshot %+v, want closed with 1 failure", s)
+	}
+}
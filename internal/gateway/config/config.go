@@ -0,0 +1,134 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Port                 string
+	RoutesFile           string
+	LogLevel             string
+	LogFormat            string
+	TraceExporter        string
+	RoutesReloadInterval time.Duration
+
+	// Proxies in front of the gateway whose X-Forwarded-For is believed;
+	// empty means clients connect directly and the peer address is used
+	TrustedProxies []string
+
+	// Upstream transport tuning
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+
+	// Token verification at the edge
+	VerifyTokens   bool
+	JWKSURL        string
+	IdentitySecret string
+
+	// Rate limiting store: "memory" or "redis"
+	RateLimitStore string
+	RedisAddr      string
+	RedisPassword  string
+	RedisDB        int
+}
+
+func LoadConfig() *Config {
+	// Load config.env if present
+	err := godotenv.Load("config.env")
+	if err != nil {
+		slog.Info("config.env not found, using environment variables")
+	}
+
+	return &Config{
+		Port:                 getEnv("GATEWAY_PORT", "8090"),
+		RoutesFile:           getEnv("GATEWAY_ROUTES_FILE", "routes.yaml"),
+		LogLevel:             getEnv("LOG_LEVEL", "info"),
+		LogFormat:            getEnv("LOG_FORMAT", "json"),
+		TraceExporter:        getEnv("TRACE_EXPORTER", "none"),
+		RoutesReloadInterval: getDuration("GATEWAY_ROUTES_RELOAD_INTERVAL", 5*time.Second),
+		TrustedProxies:       getList("GATEWAY_TRUSTED_PROXIES"),
+
+		DialTimeout:           getDuration("GATEWAY_DIAL_TIMEOUT", 5*time.Second),
+		ResponseHeaderTimeout: getDuration("GATEWAY_RESPONSE_HEADER_TIMEOUT", 30*time.Second),
+		IdleConnTimeout:       getDuration("GATEWAY_IDLE_CONN_TIMEOUT", 90*time.Second),
+		MaxIdleConns:          getInt("GATEWAY_MAX_IDLE_CONNS", 256),
+		MaxIdleConnsPerHost:   getInt("GATEWAY_MAX_IDLE_CONNS_PER_HOST", 64),
+		MaxConnsPerHost:       getInt("GATEWAY_MAX_CONNS_PER_HOST", 0),
+
+		VerifyTokens:   getBool("GATEWAY_VERIFY_TOKENS", false),
+		JWKSURL:        getEnv("JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		IdentitySecret: getEnv("IDENTITY_SECRET", ""),
+
+		RateLimitStore: getEnv("GATEWAY_RATE_LIMIT_STORE", "memory"),
+		RedisAddr:      getEnv("GATEWAY_REDIS_ADDR", "localhost:6379"),
+		RedisPassword:  getEnv("GATEWAY_REDIS_PASSWORD", ""),
+		RedisDB:        getInt("GATEWAY_REDIS_DB", 0),
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using default", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
+
+func getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getList splits a comma-separated variable, dropping empty entries
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
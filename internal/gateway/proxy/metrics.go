@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Latency of individual upstream attempts by upstream, instance and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream", "instance", "status"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Failed upstream attempts by upstream and reason.",
+	}, []string{"upstream", "reason"})
+
+	upstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Upstream attempts that were retries of a failed attempt.",
+	}, []string{"upstream"})
+)
+
+// Error reasons used as the "reason" label
+const (
+	reasonCircuitOpen = "circuit_open"
+	reasonNoInstance  = "no_healthy_instance"
+	reasonTimeout     = "timeout"
+	reasonCanceled    = "canceled"
+	reasonConnection  = "connection"
+	reasonStatus      = "bad_status"
+)
+
+// observeAttempt records the latency and, for failures, the reason of one
+// upstream attempt
+func observeAttempt(ctx context.Context, upstream, instance string, start time.Time, resp *http.Response, err error) {
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamDuration.WithLabelValues(upstream, instance, status).Observe(time.Since(start).Seconds())
+
+	switch {
+	case err != nil && ctx.Err() == context.Canceled:
+		upstreamErrors.WithLabelValues(upstream, reasonCanceled).Inc()
+	case err != nil && isTimeout(err):
+		upstreamErrors.WithLabelValues(upstream, reasonTimeout).Inc()
+	case err != nil:
+		upstreamErrors.WithLabelValues(upstream, reasonConnection).Inc()
+	case isFailure(ctx, resp, nil):
+		upstreamErrors.WithLabelValues(upstream, reasonStatus).Inc()
+	}
+}
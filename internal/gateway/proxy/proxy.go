@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+
+	"enchanted-micro/internal/gateway/breaker"
+	"enchanted-micro/internal/gateway/config"
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/gateway/upstream"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// corsHeaders are owned by the gateway; upstream copies are dropped so the
+// browser never sees duplicated values.
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// Policy is the per-request resilience configuration resolved from the route
+type Policy struct {
+	Retry   routes.RetryPolicy
+	Timeout time.Duration
+}
+
+type forwardKey struct{}
+
+type forward struct {
+	pool   *upstream.Pool
+	key    string
+	policy Policy
+	// clientIP is the peer address, or the address a trusted proxy
+	// forwarded for
+	clientIP string
+}
+
+func forwardFrom(ctx context.Context) *forward {
+	return ctx.Value(forwardKey{}).(*forward)
+}
+
+// Proxy is a streaming reverse proxy shared by all routes. Request and
+// response bodies are copied through without being buffered, and upstream
+// connections are pooled by a single transport.
+type Proxy struct {
+	rp *httputil.ReverseProxy
+}
+
+// NewTransport builds the shared upstream transport from the gateway config
+func NewTransport(cfg *config.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// New builds the proxy on top of transport. Every upstream attempt gets its
+// own client span and carries the trace context in the traceparent header.
+func New(transport http.RoundTripper) *Proxy {
+	traced := otelhttp.NewTransport(transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + forwardFrom(r.Context()).pool.Name
+		}),
+	)
+
+	p := &Proxy{}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite:        rewrite,
+		Transport:      &retryTransport{base: traced},
+		ModifyResponse: modifyResponse,
+		ErrorHandler:   errorHandler,
+	}
+	return p
+}
+
+// Serve forwards the request to an instance of pool. The request path must
+// already be rewritten to the upstream path; key feeds consistent hashing.
+func (p *Proxy) Serve(c *gin.Context, pool *upstream.Pool, key string, policy Policy) {
+	ctx := context.WithValue(c.Request.Context(), forwardKey{}, &forward{pool: pool, key: key, policy: policy, clientIP: c.ClientIP()})
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+	p.rp.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+// rewrite prepares the outbound request; the instance is chosen per attempt
+// by retryTransport.
+func rewrite(pr *httputil.ProxyRequest) {
+	pr.Out.Host = ""
+
+	// The gateway is the edge: services get the client IP it determined,
+	// never a chain the client wrote
+	pr.SetXForwarded()
+	pr.Out.Header.Set("X-Forwarded-For", forwardFrom(pr.In.Context()).clientIP)
+}
+
+func modifyResponse(resp *http.Response) error {
+	for _, h := range corsHeaders {
+		resp.Header.Del(h)
+	}
+	// The gateway already set the request ID on the response
+	resp.Header.Del(requestid.Header)
+	return nil
+}
+
+func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	logger.FromContext(r.Context()).Warn("proxy error", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	switch {
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, upstream.ErrNoHealthyInstance):
+		retryAfter := forwardFrom(r.Context()).pool.RetryAfter()
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+		writeError(w, r, http.StatusServiceUnavailable, "Service temporarily unavailable")
+	case isTimeout(err):
+		writeError(w, r, http.StatusGatewayTimeout, "Service timed out")
+	default:
+		writeError(w, r, http.StatusBadGateway, "Failed to reach service")
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	body := gin.H{"error": message}
+	if id := requestid.FromContext(r.Context()); id != "" {
+		body["request_id"] = id
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/gateway/upstream"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForwardedForIsSetByGateway(t *testing.T) {
+	var got []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Values("X-Forwarded-For")
+	}))
+	defer backend.Close()
+
+	table, err := routes.Parse([]byte(`
+upstreams:
+  svc:
+    url: `+backend.URL+`
+    health_check:
+      disabled: true
+routes:
+  - name: svc
+    prefix: /
+    upstream: svc
+`), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := upstream.NewPool("svc", table.Upstreams["svc"])
+
+	tests := []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{name: "forged chain dropped", trusted: nil, want: "127.0.0.1"},
+		{name: "trusted proxy", trusted: []string{"127.0.0.1"}, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			if err := engine.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			p := New(http.DefaultTransport)
+			engine.NoRoute(func(c *gin.Context) { p.Serve(c, pool, "", Policy{}) })
+			gateway := httptest.NewServer(engine)
+			defer gateway.Close()
+
+			got = nil
+			req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/", nil)
+			req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.1")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", resp.StatusCode)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("X-Forwarded-For %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
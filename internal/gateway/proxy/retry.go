@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/gateway/upstream"
+)
+
+// retryTransport picks an upstream instance for every attempt and applies
+// the pool's circuit breaker and the route's retry policy.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fw := forwardFrom(req.Context())
+
+	attempts := 1
+	if isIdempotent(req.Method) && isReplayable(req) {
+		attempts = fw.policy.Retry.Attempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			upstreamRetries.WithLabelValues(fw.pool.Name).Inc()
+			if err := sleep(req.Context(), backoff(fw.policy.Retry, attempt-1)); err != nil {
+				return nil, err
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+
+		inst, err := fw.pool.Pick(fw.key)
+		if err != nil {
+			upstreamErrors.WithLabelValues(fw.pool.Name, reasonNoInstance).Inc()
+			return nil, err
+		}
+		done, err := fw.pool.Breaker().Allow()
+		if err != nil {
+			upstreamErrors.WithLabelValues(fw.pool.Name, reasonCircuitOpen).Inc()
+			return nil, err
+		}
+
+		release := inst.Acquire()
+		start := time.Now()
+		resp, err := t.base.RoundTrip(toInstance(req, inst))
+		observeAttempt(req.Context(), fw.pool.Name, inst.URL, start, resp, err)
+		failed := isFailure(req.Context(), resp, err)
+		done(!failed)
+
+		if resp == nil {
+			release()
+		} else {
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		}
+
+		if !failed || attempt >= attempts {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+	}
+}
+
+// toInstance addresses a copy of req to the given instance
+func toInstance(req *http.Request, inst *upstream.Instance) *http.Request {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = inst.Target.Scheme
+	out.URL.Host = inst.Target.Host
+	out.URL.Path = strings.TrimSuffix(inst.Target.Path, "/") + req.URL.Path
+	out.Host = ""
+	return out
+}
+
+// releaseBody ends the instance's in-flight count once the body is consumed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// isFailure reports whether the outcome counts against the upstream.
+// Requests abandoned by the client are not the upstream's fault.
+func isFailure(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() != context.Canceled
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff returns an exponentially growing delay with equal jitter
+func backoff(policy routes.RetryPolicy, retry int) time.Duration {
+	d := policy.Backoff.Std() << (retry - 1)
+	if d <= 0 || d > policy.MaxBackoff.Std() {
+		d = policy.MaxBackoff.Std()
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// stores returns every Store implementation; RedisStore runs its Lua script
+// against an in-process Redis
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  NewRedisStore(client, "test:"),
+	}
+}
+
+type take struct {
+	after     time.Duration // since the first take
+	allowed   bool
+	remaining int
+	retry     time.Duration
+}
+
+func TestTake(t *testing.T) {
+	// 1 token per second, bursts of 3
+	limit := PerPeriod(60, time.Minute, 3)
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst then empty",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2},
+				{after: 0, allowed: true, remaining: 1},
+				{after: 0, allowed: true, remaining: 0},
+				{after: 0, allowed: false, remaining: 0, retry: time.Second},
+			},
+		},
+		{
+			name: "refill one token",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2},
+				{after: 0, allowed: true, remaining: 1},
+				{after: 0, allowed: true, remaining: 0},
+				{after: 500 * time.Millisecond, allowed: false, remaining: 0, retry: 500 * time.Millisecond},
+				{after: time.Second, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name: "refill stops at burst",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2},
+				{after: time.Hour, allowed: true, remaining: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for name, store := range stores(t) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				start := time.Unix(1_700_000_000, 0)
+				for i, want := range tt.takes {
+					got, err := store.Take(context.Background(), tt.name, limit, start.Add(want.after))
+					if err != nil {
+						t.Fatalf("take %d: %v", i, err)
+					}
+					if got.Allowed != want.allowed || got.Remaining != want.remaining || got.Limit != 3 {
+						t.Fatalf("take %d: got allowed=%v remaining=%d limit=%d, want allowed=%v remaining=%d limit=3",
+							i, got.Allowed, got.Remaining, got.Limit, want.allowed, want.remaining)
+					}
+					if diff := got.RetryAfter - want.retry; diff < -time.Millisecond || diff > time.Millisecond {
+						t.Fatalf("take %d: retry after %v, want %v", i, got.RetryAfter, want.retry)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTakeSeparateKeys(t *testing.T) {
+	limit := PerPeriod(1, time.Minute, 1)
+	now := time.Unix(1_700_000_000, 0)
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a", "b"} {
+				res, err := store.Take(context.Background(), key, limit, now)
+				if err != nil || !res.Allowed {
+					t.Fatalf("first take of %q: %+v, %v", key, res, err)
+				}
+			}
+			res, err := store.Take(context.Background(), "a", limit, now)
+			if err != nil || res.Allowed {
+				t.Fatalf("second take of a: %+v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestRedisStoreExpiresBuckets(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	store := NewRedisStore(client, "test:")
+
+	limit := PerPeriod(60, time.Minute, 3)
+	if _, err := store.Take(context.Background(), "k", limit, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// A full refill takes 3s; the key lives one second longer
+	if ttl := mr.TTL("test:k"); ttl != 4*time.Second {
+		t.Fatalf("ttl %v, want 4s", ttl)
+	}
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"enchanted-micro/internal/gateway/ratelimit"
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimit takes a token from every policy of the route, stopping at the
+// first empty bucket, and reports the most restrictive bucket in
+// X-RateLimit-* headers. It writes a 429 and returns false when a bucket is
+// empty. Store errors fail open.
+func (r *Router) rateLimit(c *gin.Context, table *routes.Table, route *routes.Route) bool {
+	if r.limiter == nil || len(route.RateLimits) == 0 {
+		return true
+	}
+
+	now := time.Now()
+	var tightest *ratelimit.Result
+	for _, name := range route.RateLimits {
+		policy := table.RateLimitPolicies[name]
+		key, ok := limitKey(c, route, policy.Key)
+		if !ok {
+			continue
+		}
+
+		bucket := name + ":" + key
+		if policy.PerRoute {
+			bucket = name + ":" + route.Name + ":" + key
+		}
+		limit := ratelimit.PerPeriod(policy.Requests, policy.Per.Std(), policy.Burst)
+		res, err := r.limiter.Take(c.Request.Context(), bucket, limit, now)
+		if err != nil {
+			logger.FromGin(c).Error("rate limit store failed, allowing request", "policy", name, "error", err)
+			continue
+		}
+		if tightest == nil || tighter(res, *tightest) {
+			tightest = &res
+		}
+		if !res.Allowed {
+			// Later buckets keep their tokens for requests that get through
+			break
+		}
+	}
+	if tightest == nil {
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.ResetAfter)))
+
+	if !tightest.Allowed {
+		c.Header("Retry-After", strconv.Itoa(max(1, ceilSeconds(tightest.RetryAfter))))
+		response.Error(c, http.StatusTooManyRequests, "Too many requests")
+		return false
+	}
+	return true
+}
+
+// limitKey derives the bucket key for a policy. Per-user policies only apply
+// to identities verified by the gateway; an unverified user_id could be used
+// to drain somebody else's quota.
+func limitKey(c *gin.Context, route *routes.Route, by string) (string, bool) {
+	switch by {
+	case routes.LimitByUser:
+		id := identity.FromContext(c)
+		if id == nil {
+			return "", false
+		}
+		return "user:" + strconv.FormatUint(uint64(id.UserID), 10), true
+	case routes.LimitByRoute:
+		return "route:" + route.Name, true
+	default:
+		return "ip:" + c.ClientIP(), true
+	}
+}
+
+func tighter(a, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/gateway/ratelimit"
+	"enchanted-micro/internal/gateway/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// send runs the rate limiter of the route matching method and path for a
+// request from peer with the given X-Forwarded-For
+func send(t *testing.T, r *Router, trusted []string, method, path, peer, forwardedFor string) *httptest.ResponseRecorder {
+	t.Helper()
+	table := routes.DefaultTable()
+	route, _, ok := table.Match(method, path)
+	if !ok {
+		t.Fatalf("no route for %s %s", method, path)
+	}
+
+	w := httptest.NewRecorder()
+	c, engine := gin.CreateTestContext(w)
+	if err := engine.SetTrustedProxies(trusted); err != nil {
+		t.Fatal(err)
+	}
+	c.Request = httptest.NewRequest(method, path, nil)
+	c.Request.RemoteAddr = peer + ":40000"
+	if forwardedFor != "" {
+		c.Request.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	if r.rateLimit(c, table, route) {
+		c.Status(http.StatusOK)
+	}
+	return w
+}
+
+// login sends a request to the rate limiter of the user-auth route (5
+// requests burst)
+func login(t *testing.T, r *Router, trusted []string, peer, forwardedFor string) *httptest.ResponseRecorder {
+	t.Helper()
+	return send(t, r, trusted, http.MethodPost, "/user/login", peer, forwardedFor)
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	r := &Router{limiter: ratelimit.NewMemoryStore()}
+
+	// Without trusted proxies every forged address lands in the peer's bucket
+	for i := range 5 {
+		w := login(t, r, nil, "203.0.113.7", fmt.Sprintf("10.0.0.%d", i))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+	w := login(t, r, nil, "203.0.113.7", "10.0.0.99")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed request: status %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	r := &Router{limiter: ratelimit.NewMemoryStore()}
+	trusted := []string{"192.0.2.1"}
+
+	// Behind a trusted proxy each forwarded client has its own bucket
+	for i := range 10 {
+		w := login(t, r, trusted, "192.0.2.1", fmt.Sprintf("198.51.100.%d", i))
+		if w.Code != http.StatusOK {
+			t.Fatalf("client %d: status %d", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	r := &Router{limiter: ratelimit.NewMemoryStore()}
+
+	tests := []struct {
+		status     int
+		remaining  string
+		retryAfter string
+	}{
+		{http.StatusOK, "4", ""},
+		{http.StatusOK, "3", ""},
+		{http.StatusOK, "2", ""},
+		{http.StatusOK, "1", ""},
+		{http.StatusOK, "0", ""},
+		// auth allows 10 requests per minute: one token every 6s
+		{http.StatusTooManyRequests, "0", "6"},
+	}
+	for i, tt := range tests {
+		w := login(t, r, nil, "203.0.113.8", "")
+		if w.Code != tt.status {
+			t.Fatalf("request %d: status %d, want %d", i, w.Code, tt.status)
+		}
+		h := w.Header()
+		if h.Get("X-RateLimit-Limit") != "5" || h.Get("X-RateLimit-Remaining") != tt.remaining {
+			t.Fatalf("request %d: limit %q remaining %q, want 5 and %s", i, h.Get("X-RateLimit-Limit"), h.Get("X-RateLimit-Remaining"), tt.remaining)
+		}
+		if got := h.Get("Retry-After"); got != tt.retryAfter {
+			t.Fatalf("request %d: Retry-After %q, want %q", i, got, tt.retryAfter)
+		}
+		if reset, err := strconv.Atoi(h.Get("X-RateLimit-Reset")); err != nil || reset < 0 {
+			t.Fatalf("request %d: X-RateLimit-Reset %q", i, h.Get("X-RateLimit-Reset"))
+		}
+	}
+}
+
+func TestRateLimitAuthBucketsPerRoute(t *testing.T) {
+	r := &Router{limiter: ratelimit.NewMemoryStore()}
+
+	for range 6 {
+		login(t, r, nil, "203.0.113.9", "")
+	}
+	if w := login(t, r, nil, "203.0.113.9", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("login: status %d, want 429", w.Code)
+	}
+
+	// Other auth routes and routes without the auth policy are unaffected
+	tests := []struct{ method, path string }{
+		{http.MethodPost, "/user/password/forgot"},
+		{http.MethodPost, "/user/register"},
+		{http.MethodGet, "/user/account/export"},
+	}
+	for _, tt := range tests {
+		if w := send(t, r, nil, tt.method, tt.path, "203.0.113.9", ""); w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d, want 200", tt.method, tt.path, w.Code)
+		}
+	}
+}
+
+func TestRateLimitStopsAtFirstDenial(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	r := &Router{limiter: store}
+
+	// 5 allowed logins, then denials that must not drain per-ip
+	for range 20 {
+		login(t, r, nil, "203.0.113.10", "")
+	}
+
+	table := routes.DefaultTable()
+	policy := table.RateLimitPolicies["per-ip"]
+	limit := ratelimit.PerPeriod(policy.Requests, policy.Per.Std(), policy.Burst)
+	res, err := store.Take(context.Background(), "per-ip:ip:203.0.113.10", limit, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := policy.Burst - 6; res.Remaining != want {
+		t.Fatalf("per-ip remaining %d, want %d", res.Remaining, want)
+	}
+}
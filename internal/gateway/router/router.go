@@ -0,0 +1,201 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"enchanted-micro/internal/gateway/config"
+	"enchanted-micro/internal/gateway/proxy"
+	"enchanted-micro/internal/gateway/ratelimit"
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/gateway/upstream"
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Router dispatches gateway requests using the current route table.
+// The table is swapped atomically so reloads never block in-flight requests.
+type Router struct {
+	cfg     *config.Config
+	state   atomic.Pointer[state]
+	proxy   *proxy.Proxy
+	client  *http.Client
+	limiter ratelimit.Store
+	keys    identity.Keys
+}
+
+// state is everything derived from one version of the route table
+type state struct {
+	table *routes.Table
+	pools map[string]*upstream.Pool
+}
+
+// New builds a router; client is used for active upstream health checks,
+// limiter holds rate limit buckets (nil disables rate limiting) and keys
+// verify tokens when GATEWAY_VERIFY_TOKENS is set
+func New(cfg *config.Config, table *routes.Table, p *proxy.Proxy, client *http.Client, limiter ratelimit.Store, keys identity.Keys) *Router {
+	r := &Router{cfg: cfg, proxy: p, client: client, limiter: limiter, keys: keys}
+	r.Reload(table)
+	return r
+}
+
+// Table returns the route table currently in use
+func (r *Router) Table() *routes.Table {
+	return r.state.Load().table
+}
+
+// Reload replaces the active route table. Pools of upstreams whose
+// definition did not change are carried over so a reload does not reset
+// their breaker or health state; the others are rebuilt.
+func (r *Router) Reload(table *routes.Table) {
+	var previous map[string]*upstream.Pool
+	if old := r.state.Load(); old != nil {
+		previous = old.pools
+	}
+
+	next := &state{table: table, pools: make(map[string]*upstream.Pool, len(table.Upstreams))}
+	for name, cfg := range table.Upstreams {
+		if pool, ok := previous[name]; ok && reflect.DeepEqual(pool.Config(), cfg) {
+			next.pools[name] = pool
+			continue
+		}
+		pool := upstream.NewPool(name, cfg)
+		pool.Start(r.client)
+		next.pools[name] = pool
+	}
+
+	r.state.Store(next)
+
+	for name, pool := range previous {
+		if next.pools[name] != pool {
+			pool.Stop()
+		}
+	}
+}
+
+// Handle is registered as the gin NoRoute handler and proxies every
+// request that matches the route table.
+func (r *Router) Handle(c *gin.Context) {
+	s := r.state.Load()
+
+	route, path, ok := s.table.Match(c.Request.Method, c.Request.URL.Path)
+	if !ok {
+		response.Error(c, http.StatusNotFound, "Route not found")
+		return
+	}
+	c.Set(metrics.RouteKey, route.Name)
+
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetName(c.Request.Method + " " + route.Prefix)
+	span.SetAttributes(
+		semconv.HTTPRoute(route.Prefix),
+		attribute.String("gateway.route", route.Name),
+		attribute.String("gateway.upstream", route.Upstream),
+	)
+
+	// Identity headers and the internal API secret are only ever set by
+	// the gateway and the services themselves
+	identity.Strip(c.Request.Header)
+	c.Request.Header.Del(internalapi.Header)
+	if !r.authenticate(c, route) {
+		return
+	}
+	if !r.rateLimit(c, s.table, route) {
+		return
+	}
+
+	pool := s.pools[route.Upstream]
+	var key string
+	if pool.UsesKey() {
+		key = hashKey(c)
+	}
+
+	c.Request.URL.Path = path
+	c.Request.URL.RawPath = ""
+	r.signIdentity(c)
+	r.proxy.Serve(c, pool, key, proxy.Policy{
+		Retry:   route.Retry,
+		Timeout: route.Timeout.Std(),
+	})
+}
+
+// Status returns the state of every upstream pool
+func (r *Router) Status() map[string]upstream.Status {
+	s := r.state.Load()
+
+	status := make(map[string]upstream.Status, len(s.pools))
+	for name, pool := range s.pools {
+		status[name] = pool.Status()
+	}
+	return status
+}
+
+// LoadTable reads the route file, falling back to the built-in table when
+// the file does not exist.
+func LoadTable(path string) (*routes.Table, error) {
+	table, err := routes.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("route file not found, using default routes", "path", path)
+		return routes.DefaultTable(), nil
+	}
+	return table, err
+}
+
+// WatchFile polls the route file and reloads the table whenever it changes.
+// Invalid files are logged and the previous table stays active.
+func (r *Router) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	lastMod := modTime(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mod := modTime(path)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			r.ReloadFile(path)
+		}
+	}
+}
+
+// ReloadFile reloads the table from path, keeping the current table on error
+func (r *Router) ReloadFile(path string) {
+	table, err := LoadTable(path)
+	if err != nil {
+		slog.Error("route table reload failed, keeping previous routes", "path", path, "error", err)
+		return
+	}
+	r.Reload(table)
+	slog.Info("route table reloaded", "path", path, "routes", len(table.Routes))
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
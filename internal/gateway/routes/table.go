@@ -0,0 +1,421 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/gateway/breaker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AuthMode tells the gateway what a route expects from the Authorization header
+type AuthMode string
+
+const (
+	AuthNone     AuthMode = "none"
+	AuthOptional AuthMode = "optional"
+	AuthRequired AuthMode = "required"
+)
+
+// Balancer names accepted in upstream definitions
+const (
+	BalanceRoundRobin     = "round_robin"
+	BalanceLeastConn      = "least_connections"
+	BalanceConsistentHash = "consistent_hash"
+)
+
+// Upstream is a backend service the gateway can forward to. It is served by
+// one or more instances; url and every entry of instances may hold a
+// comma-separated list so replicas can be passed in a single env variable.
+type Upstream struct {
+	URL            string             `yaml:"url" json:"url"`
+	Instances      []string           `yaml:"instances" json:"instances"`
+	Balancer       string             `yaml:"balancer" json:"balancer"`
+	HealthCheck    *HealthCheckConfig `yaml:"health_check" json:"health_check"`
+	CircuitBreaker *BreakerConfig     `yaml:"circuit_breaker" json:"circuit_breaker"`
+
+	targets []*url.URL
+}
+
+// Targets returns the parsed URL of every instance
+func (u Upstream) Targets() []*url.URL {
+	return u.targets
+}
+
+// HealthCheckConfig controls active probing of upstream instances
+type HealthCheckConfig struct {
+	Disabled           bool     `yaml:"disabled" json:"disabled"`
+	Path               string   `yaml:"path" json:"path"`
+	Interval           Duration `yaml:"interval" json:"interval"`
+	Timeout            Duration `yaml:"timeout" json:"timeout"`
+	HealthyThreshold   int      `yaml:"healthy_threshold" json:"healthy_threshold"`
+	UnhealthyThreshold int      `yaml:"unhealthy_threshold" json:"unhealthy_threshold"`
+}
+
+// BreakerConfig overrides breaker.DefaultSettings for one upstream
+type BreakerConfig struct {
+	FailureThreshold int      `yaml:"failure_threshold" json:"failure_threshold"`
+	OpenTimeout      Duration `yaml:"open_timeout" json:"open_timeout"`
+	HalfOpenRequests int      `yaml:"half_open_requests" json:"half_open_requests"`
+}
+
+// BreakerSettings returns the breaker settings for the upstream
+func (u Upstream) BreakerSettings() breaker.Settings {
+	if u.CircuitBreaker == nil {
+		return breaker.DefaultSettings
+	}
+	return breaker.Settings{
+		FailureThreshold: u.CircuitBreaker.FailureThreshold,
+		OpenTimeout:      u.CircuitBreaker.OpenTimeout.Std(),
+		HalfOpenRequests: u.CircuitBreaker.HalfOpenRequests,
+	}.WithDefaults()
+}
+
+// RetryPolicy controls retries of idempotent requests on a route
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first one
+	Attempts   int      `yaml:"attempts" json:"attempts"`
+	Backoff    Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+// Rate limit keys
+const (
+	LimitByIP    = "ip"
+	LimitByUser  = "user"
+	LimitByRoute = "route"
+)
+
+// RateLimitPolicy allows Requests per Per for each key, with bursts up to Burst
+type RateLimitPolicy struct {
+	Key      string   `yaml:"key" json:"key"`
+	Requests int      `yaml:"requests" json:"requests"`
+	Per      Duration `yaml:"per" json:"per"`
+	Burst    int      `yaml:"burst" json:"burst"`
+	// PerRoute gives every route using the policy its own buckets instead of
+	// one budget shared by all of them
+	PerRoute bool `yaml:"per_route" json:"per_route"`
+}
+
+// Route maps a path prefix (and optionally a set of methods) to an upstream
+type Route struct {
+	Name        string   `yaml:"name" json:"name"`
+	Prefix      string   `yaml:"prefix" json:"prefix"`
+	Methods     []string `yaml:"methods" json:"methods"`
+	Upstream    string   `yaml:"upstream" json:"upstream"`
+	StripPrefix bool     `yaml:"strip_prefix" json:"strip_prefix"`
+	Rewrite     string   `yaml:"rewrite" json:"rewrite"`
+	Auth        AuthMode `yaml:"auth" json:"auth"`
+
+	// Timeout bounds the whole upstream exchange; zero means no limit
+	Timeout Duration    `yaml:"timeout" json:"timeout"`
+	Retry   RetryPolicy `yaml:"retry" json:"retry"`
+
+	// RateLimits names the policies applied to the route; when omitted the
+	// table's default_rate_limits apply, an empty list disables limiting
+	RateLimits []string `yaml:"rate_limits" json:"rate_limits"`
+}
+
+// Table is the declarative route table loaded from GATEWAY_ROUTES_FILE
+type Table struct {
+	Upstreams         map[string]Upstream        `yaml:"upstreams" json:"upstreams"`
+	RateLimitPolicies map[string]RateLimitPolicy `yaml:"rate_limit_policies" json:"rate_limit_policies"`
+	DefaultRateLimits []string                   `yaml:"default_rate_limits" json:"default_rate_limits"`
+	Routes            []Route                    `yaml:"routes" json:"routes"`
+}
+
+// Load reads a route table from a YAML or JSON file. ${VAR} and ${VAR:-default}
+// references are expanded from the environment before parsing.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, filepath.Ext(path))
+}
+
+// Parse decodes a route table; ext selects the format (".json" or YAML otherwise)
+func Parse(data []byte, ext string) (*Table, error) {
+	expanded := os.Expand(string(data), expandEnv)
+
+	var t Table
+	if strings.EqualFold(ext, ".json") {
+		err := json.Unmarshal([]byte(expanded), &t)
+		if err != nil {
+			return nil, fmt.Errorf("parse route table: %w", err)
+		}
+	} else {
+		err := yaml.Unmarshal([]byte(expanded), &t)
+		if err != nil {
+			return nil, fmt.Errorf("parse route table: %w", err)
+		}
+	}
+
+	if err := t.normalize(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DefaultTable is used when no route file exists. It mirrors the routes the
+// gateway always had, with upstream URLs taken from the environment.
+func DefaultTable() *Table {
+	apiTimeout := Duration(15 * time.Second)
+	readRetry := RetryPolicy{Attempts: 3, Backoff: Duration(100 * time.Millisecond), MaxBackoff: Duration(time.Second)}
+
+	t := &Table{
+		Upstreams: map[string]Upstream{
+			"user":    {URL: envOr("USER_SERVICE_URL", "http://localhost:8080")},
+			"product": {URL: envOr("PRODUCT_SERVICE_URL", "http://localhost:8081")},
+		},
+		RateLimitPolicies: map[string]RateLimitPolicy{
+			"per-ip":   {Key: LimitByIP, Requests: 300, Per: Duration(time.Minute), Burst: 60},
+			"per-user": {Key: LimitByUser, Requests: 600, Per: Duration(time.Minute), Burst: 120},
+			"auth":     {Key: LimitByIP, Requests: 10, Per: Duration(time.Minute), Burst: 5, PerRoute: true},
+		},
+		DefaultRateLimits: []string{"per-ip", "per-user"},
+		Routes: []Route{
+			{Name: "user-auth", Prefix: "/user/login", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/login", RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-register", Prefix: "/user/register", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/register", RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-profile", Prefix: "/user/profile", Upstream: "user", Rewrite: "/profile", Auth: AuthRequired, Timeout: apiTimeout, Retry: readRetry},
+			{Name: "user-logout", Prefix: "/user/logout", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/logout", Auth: AuthRequired},
+			{Name: "user-logout-all", Prefix: "/user/logout-all", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/logout-all", Auth: AuthRequired},
+			{Name: "user-password", Prefix: "/user/password", Methods: []string{"PUT"}, Upstream: "user", Rewrite: "/password", Auth: AuthRequired, RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-password-forgot", Prefix: "/user/password/forgot", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/password/forgot", RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-password-reset", Prefix: "/user/password/reset", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/password/reset", RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-2fa", Prefix: "/user/2fa", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/2fa", Auth: AuthRequired, RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-oauth", Prefix: "/user/oauth", Upstream: "user", Rewrite: "/oauth", Timeout: apiTimeout, RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-verify-email-resend", Prefix: "/user/verify-email/resend", Methods: []string{"POST"}, Upstream: "user", Rewrite: "/verify-email/resend", Auth: AuthRequired, RateLimits: []string{"auth", "per-ip"}},
+			{Name: "user-api-keys", Prefix: "/user/api-keys", Upstream: "user", Rewrite: "/api-keys", Auth: AuthRequired, Timeout: apiTimeout},
+			{Name: "user-sessions", Prefix: "/user/sessions", Upstream: "user", Rewrite: "/sessions", Auth: AuthRequired, Timeout: apiTimeout},
+			{Name: "user-account", Prefix: "/user/account", Upstream: "user", Rewrite: "/account", Auth: AuthRequired, Timeout: Duration(2 * time.Minute)},
+			{Name: "user-admin", Prefix: "/user/admin", Upstream: "user", Rewrite: "/admin", Auth: AuthRequired, Timeout: apiTimeout},
+			{Name: "user", Prefix: "/user", Upstream: "user", StripPrefix: true, Timeout: apiTimeout, Retry: readRetry},
+			{Name: "products-write", Prefix: "/products", Methods: []string{"POST", "PUT", "DELETE"}, Upstream: "product", Auth: AuthRequired},
+			{Name: "products", Prefix: "/products", Upstream: "product", Timeout: apiTimeout, Retry: readRetry},
+			{Name: "my-products", Prefix: "/my-products", Upstream: "product", Auth: AuthRequired, Timeout: apiTimeout, Retry: readRetry},
+			{Name: "uploads", Prefix: "/uploads", Upstream: "product", Retry: readRetry},
+			{Name: "avatars", Prefix: "/avatars", Upstream: "user", Retry: readRetry},
+		},
+	}
+	if err := t.normalize(); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Match returns the route for a request and the path to send upstream.
+// The longest matching prefix wins; on ties the route listed first wins.
+func (t *Table) Match(method, path string) (*Route, string, bool) {
+	for i := range t.Routes {
+		route := &t.Routes[i]
+		if !route.allows(method) || !hasPathPrefix(path, route.Prefix) {
+			continue
+		}
+		return route, route.upstreamPath(path), true
+	}
+	return nil, "", false
+}
+
+// normalize validates the table and sorts routes for matching
+func (t *Table) normalize() error {
+	if len(t.Routes) == 0 {
+		return fmt.Errorf("route table has no routes")
+	}
+
+	for name, upstream := range t.Upstreams {
+		if err := upstream.normalize(); err != nil {
+			return fmt.Errorf("upstream %q: %w", name, err)
+		}
+		t.Upstreams[name] = upstream
+	}
+
+	for name, policy := range t.RateLimitPolicies {
+		switch policy.Key {
+		case LimitByIP, LimitByUser, LimitByRoute:
+		default:
+			return fmt.Errorf("rate limit policy %q: unknown key %q", name, policy.Key)
+		}
+		if policy.Requests <= 0 || policy.Per <= 0 {
+			return fmt.Errorf("rate limit policy %q: requests and per must be positive", name)
+		}
+		if policy.Burst <= 0 {
+			policy.Burst = policy.Requests
+		}
+		t.RateLimitPolicies[name] = policy
+	}
+	if err := t.checkPolicies("default_rate_limits", t.DefaultRateLimits); err != nil {
+		return err
+	}
+
+	for i := range t.Routes {
+		route := &t.Routes[i]
+		if route.Name == "" {
+			route.Name = route.Prefix
+		}
+		if !strings.HasPrefix(route.Prefix, "/") {
+			return fmt.Errorf("route %q: prefix must start with /", route.Name)
+		}
+		if _, ok := t.Upstreams[route.Upstream]; !ok {
+			return fmt.Errorf("route %q: unknown upstream %q", route.Name, route.Upstream)
+		}
+		if route.Rewrite != "" && !strings.HasPrefix(route.Rewrite, "/") {
+			return fmt.Errorf("route %q: rewrite must start with /", route.Name)
+		}
+		if route.Rewrite != "" && route.StripPrefix {
+			return fmt.Errorf("route %q: strip_prefix and rewrite are mutually exclusive", route.Name)
+		}
+		switch route.Auth {
+		case "":
+			route.Auth = AuthNone
+		case AuthNone, AuthOptional, AuthRequired:
+		default:
+			return fmt.Errorf("route %q: unknown auth mode %q", route.Name, route.Auth)
+		}
+		if route.Retry.Attempts < 1 {
+			route.Retry.Attempts = 1
+		}
+		if route.Retry.Backoff <= 0 {
+			route.Retry.Backoff = Duration(100 * time.Millisecond)
+		}
+		if route.Retry.MaxBackoff < route.Retry.Backoff {
+			route.Retry.MaxBackoff = max(route.Retry.Backoff, Duration(2*time.Second))
+		}
+		if route.RateLimits == nil {
+			route.RateLimits = t.DefaultRateLimits
+		}
+		if err := t.checkPolicies("route "+strconv.Quote(route.Name), route.RateLimits); err != nil {
+			return err
+		}
+		for j, m := range route.Methods {
+			route.Methods[j] = strings.ToUpper(m)
+		}
+	}
+
+	sort.SliceStable(t.Routes, func(i, j int) bool {
+		return len(t.Routes[i].Prefix) > len(t.Routes[j].Prefix)
+	})
+	return nil
+}
+
+func (t *Table) checkPolicies(owner string, names []string) error {
+	for _, name := range names {
+		if _, ok := t.RateLimitPolicies[name]; !ok {
+			return fmt.Errorf("%s: unknown rate limit policy %q", owner, name)
+		}
+	}
+	return nil
+}
+
+func (u *Upstream) normalize() error {
+	var urls []string
+	for _, entry := range append([]string{u.URL}, u.Instances...) {
+		for _, raw := range strings.Split(entry, ",") {
+			if raw = strings.TrimSpace(raw); raw != "" {
+				urls = append(urls, strings.TrimSuffix(raw, "/"))
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("no url or instances")
+	}
+
+	u.targets = u.targets[:0]
+	for _, raw := range urls {
+		target, err := url.Parse(raw)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return fmt.Errorf("invalid url %q", raw)
+		}
+		u.targets = append(u.targets, target)
+	}
+	u.URL = urls[0]
+	u.Instances = urls
+
+	switch u.Balancer {
+	case "":
+		u.Balancer = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastConn, BalanceConsistentHash:
+	default:
+		return fmt.Errorf("unknown balancer %q", u.Balancer)
+	}
+
+	if u.HealthCheck == nil {
+		u.HealthCheck = &HealthCheckConfig{}
+	}
+	hc := u.HealthCheck
+	if hc.Path == "" {
+		hc.Path = "/health/ready"
+	}
+	if hc.Interval <= 0 {
+		hc.Interval = Duration(10 * time.Second)
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = Duration(2 * time.Second)
+	}
+	if hc.HealthyThreshold <= 0 {
+		hc.HealthyThreshold = 2
+	}
+	if hc.UnhealthyThreshold <= 0 {
+		hc.UnhealthyThreshold = 3
+	}
+	return nil
+}
+
+func (r *Route) allows(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Route) upstreamPath(path string) string {
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(r.Prefix, "/"))
+
+	var newPath string
+	switch {
+	case r.Rewrite != "":
+		newPath = strings.TrimSuffix(r.Rewrite, "/") + rest
+	case r.StripPrefix:
+		newPath = rest
+	default:
+		newPath = path
+	}
+
+	if newPath == "" {
+		return "/"
+	}
+	return newPath
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// expandEnv resolves ${VAR} and ${VAR:-default}
+func expandEnv(key string) string {
+	name, def, _ := strings.Cut(key, ":-")
+	return envOr(name, def)
+}
+
+func envOr(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,100 @@
+package upstream
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"enchanted-micro/internal/gateway/routes"
+)
+
+// Balancer picks one of the healthy instances of a pool
+type Balancer interface {
+	Pick(instances []*Instance, key string) *Instance
+}
+
+func newBalancer(name string, instances []*Instance) Balancer {
+	switch name {
+	case routes.BalanceLeastConn:
+		return leastConn{}
+	case routes.BalanceConsistentHash:
+		return newHashRing(instances)
+	default:
+		return &roundRobin{}
+	}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) Pick(instances []*Instance, _ string) *Instance {
+	n := uint64(len(instances))
+	start := b.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		inst := instances[(start+i)%n]
+		if inst.Healthy() {
+			return inst
+		}
+	}
+	return nil
+}
+
+type leastConn struct{}
+
+func (leastConn) Pick(instances []*Instance, _ string) *Instance {
+	var best *Instance
+	for _, inst := range instances {
+		if !inst.Healthy() {
+			continue
+		}
+		if best == nil || inst.Active() < best.Active() {
+			best = inst
+		}
+	}
+	return best
+}
+
+// virtualNodes is the number of ring points per instance
+const virtualNodes = 100
+
+// hashRing is a consistent-hash balancer. The same key keeps landing on the
+// same instance while it is healthy; when it is ejected only its keys move.
+type hashRing struct {
+	points []uint32
+	owners map[uint32]*Instance
+	rr     roundRobin
+}
+
+func newHashRing(instances []*Instance) *hashRing {
+	ring := &hashRing{owners: make(map[uint32]*Instance, len(instances)*virtualNodes)}
+	for _, inst := range instances {
+		for v := 0; v < virtualNodes; v++ {
+			h := crc32.ChecksumIEEE([]byte(inst.URL + "#" + strconv.Itoa(v)))
+			if _, taken := ring.owners[h]; taken {
+				continue
+			}
+			ring.owners[h] = inst
+			ring.points = append(ring.points, h)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+func (r *hashRing) Pick(instances []*Instance, key string) *Instance {
+	if key == "" || len(r.points) == 0 {
+		return r.rr.Pick(instances, key)
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	for i := 0; i < len(r.points); i++ {
+		inst := r.owners[r.points[(start+i)%len(r.points)]]
+		if inst.Healthy() {
+			return inst
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"enchanted-micro/internal/gateway/breaker"
+	"enchanted-micro/internal/gateway/routes"
+)
+
+// ErrNoHealthyInstance is returned when every instance of a pool is ejected
+var ErrNoHealthyInstance = errors.New("no healthy upstream instance")
+
+// Instance is one replica of an upstream service
+type Instance struct {
+	URL    string
+	Target *url.URL
+
+	healthy atomic.Bool
+	active  atomic.Int64
+
+	mu        sync.Mutex
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError string
+}
+
+func (i *Instance) Healthy() bool {
+	return i.healthy.Load()
+}
+
+// Active is the number of requests currently in flight to the instance
+func (i *Instance) Active() int64 {
+	return i.active.Load()
+}
+
+// Acquire marks a request as started; the returned function ends it
+func (i *Instance) Acquire() func() {
+	i.active.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { i.active.Add(-1) })
+	}
+}
+
+// Pool is the set of instances behind one upstream name, together with its
+// balancer, circuit breaker and active health checker.
+type Pool struct {
+	Name      string
+	config    routes.Upstream
+	instances []*Instance
+	balancer  Balancer
+	breaker   *breaker.Breaker
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewPool(name string, cfg routes.Upstream) *Pool {
+	p := &Pool{
+		Name:    name,
+		config:  cfg,
+		breaker: breaker.New(cfg.BreakerSettings()),
+		stop:    make(chan struct{}),
+	}
+	for _, target := range cfg.Targets() {
+		inst := &Instance{URL: target.String(), Target: target}
+		// Instances start healthy so traffic flows before the first probe
+		inst.healthy.Store(true)
+		p.instances = append(p.instances, inst)
+	}
+	p.balancer = newBalancer(cfg.Balancer, p.instances)
+	return p
+}
+
+// Config returns the upstream definition the pool was built from
+func (p *Pool) Config() routes.Upstream {
+	return p.config
+}
+
+func (p *Pool) Breaker() *breaker.Breaker {
+	return p.breaker
+}
+
+// UsesKey reports whether Pick needs a hash key
+func (p *Pool) UsesKey() bool {
+	return p.config.Balancer == routes.BalanceConsistentHash
+}
+
+// Pick chooses an instance for a request; key is only used by consistent hashing
+func (p *Pool) Pick(key string) (*Instance, error) {
+	inst := p.balancer.Pick(p.instances, key)
+	if inst == nil {
+		return nil, ErrNoHealthyInstance
+	}
+	return inst, nil
+}
+
+// Ready reports whether the pool can serve traffic
+func (p *Pool) Ready() bool {
+	return p.CheckReady(context.Background()) == nil
+}
+
+// CheckReady explains why the pool cannot serve traffic. It feeds the
+// gateway's detailed health output only: an unavailable upstream must not
+// take the gateway itself out of rotation.
+func (p *Pool) CheckReady(context.Context) error {
+	if p.breaker.Snapshot().State == breaker.Open.String() {
+		return breaker.ErrOpen
+	}
+	for _, inst := range p.instances {
+		if inst.Healthy() {
+			return nil
+		}
+	}
+	return ErrNoHealthyInstance
+}
+
+// RetryAfter is a hint for clients when the pool cannot serve a request
+func (p *Pool) RetryAfter() time.Duration {
+	if d := p.breaker.RetryAfter(); d > 0 {
+		return d
+	}
+	return p.config.HealthCheck.Interval.Std()
+}
+
+// Start launches the active health checker
+func (p *Pool) Start(client *http.Client) {
+	if p.config.HealthCheck.Disabled {
+		return
+	}
+	go p.healthLoop(client)
+}
+
+// Stop ends the health checker; safe to call more than once
+func (p *Pool) Stop() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+func (p *Pool) healthLoop(client *http.Client) {
+	hc := p.config.HealthCheck
+	ticker := time.NewTicker(hc.Interval.Std())
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, inst := range p.instances {
+			wg.Add(1)
+			go func(inst *Instance) {
+				defer wg.Done()
+				p.probe(client, inst)
+			}(inst)
+		}
+		wg.Wait()
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) probe(client *http.Client, inst *Instance) {
+	hc := p.config.HealthCheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout.Std())
+	defer cancel()
+
+	err := check(ctx, client, inst.URL+hc.Path)
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	inst.lastCheck = time.Now()
+	if err != nil {
+		inst.lastError = err.Error()
+		inst.successes = 0
+		inst.failures++
+		if inst.failures >= hc.UnhealthyThreshold && inst.healthy.Swap(false) {
+			logStateChange(p.Name, inst, false, err)
+		}
+		return
+	}
+
+	inst.lastError = ""
+	inst.failures = 0
+	inst.successes++
+	if inst.successes >= hc.HealthyThreshold && !inst.healthy.Swap(true) {
+		logStateChange(p.Name, inst, true, nil)
+	}
+}
+
+func check(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// InstanceStatus is reported by the gateway health endpoint
+type InstanceStatus struct {
+	URL       string     `json:"url"`
+	Healthy   bool       `json:"healthy"`
+	Active    int64      `json:"active_requests"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Status describes the pool for health output
+type Status struct {
+	Balancer  string           `json:"balancer"`
+	Ready     bool             `json:"ready"`
+	Circuit   breaker.Snapshot `json:"circuit"`
+	Instances []InstanceStatus `json:"instances"`
+}
+
+func (p *Pool) Status() Status {
+	s := Status{
+		Balancer: p.config.Balancer,
+		Ready:    p.Ready(),
+		Circuit:  p.breaker.Snapshot(),
+	}
+	for _, inst := range p.instances {
+		inst.mu.Lock()
+		is := InstanceStatus{
+			URL:       inst.URL,
+			Healthy:   inst.Healthy(),
+			Active:    inst.Active(),
+			LastError: inst.lastError,
+		}
+		if !inst.lastCheck.IsZero() {
+			t := inst.lastCheck
+			is.LastCheck = &t
+		}
+		inst.mu.Unlock()
+		s.Instances = append(s.Instances, is)
+	}
+	return s
+}
+
+func logStateChange(pool string, inst *Instance, healthy bool, err error) {
+	if healthy {
+		slog.Info("upstream instance healthy", "upstream", pool, "instance", inst.URL)
+		return
+	}
+	slog.Warn("upstream instance ejected", "upstream", pool, "instance", inst.URL, "error", err)
+}
@@ -0,0 +1,194 @@
+// Package apikey generates personal API keys and verifies them across
+// services. The user service stores keys and answers verification requests
+// on an internal endpoint; other services call it through a Client, which
+// caches answers briefly so scripts do not cost a round trip per request.
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path is where the user service verifies API keys
+const Path = "/internal/api-keys/verify"
+
+// Prefix starts every key so leaked keys are easy to recognize and scan for
+const Prefix = "emk_"
+
+// displayLength is how much of a key is kept in clear to tell keys apart
+const displayLength = len(Prefix) + 8
+
+// Generate returns a new key, the hash to store and the prefix to display
+func Generate() (key, hash, display string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, Hash(key), key[:displayLength], nil
+}
+
+// Hash returns the stored form of key
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Valid reports whether key looks like a key from Generate, so obvious
+// garbage is rejected without a lookup
+func Valid(key string) bool {
+	return strings.HasPrefix(key, Prefix) && len(key) > displayLength
+}
+
+// Verifier resolves a key to its owner's identity
+type Verifier func(ctx context.Context, key string) (*identity.Identity, error)
+
+type verifyRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// Handler serves verify as JSON. Invalid keys get 404 so callers can tell
+// them apart from failures.
+func Handler(verify Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req verifyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := verify(c.Request.Context(), req.Key)
+		if errors.Is(err, identity.ErrInvalidAPIKey) {
+			response.Error(c, http.StatusNotFound, "invalid api key")
+			return
+		}
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "api key verification failed")
+			return
+		}
+		c.JSON(http.StatusOK, id)
+	}
+}
+
+// Client verifies keys against the user service at baseURL
+type Client struct {
+	endpoint string
+	secret   string
+	client   *http.Client
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cache     map[string]entry // by key hash
+	lastPrune time.Time
+}
+
+type entry struct {
+	id      *identity.Identity // nil for invalid keys
+	expires time.Time
+}
+
+// NewClient returns a client caching answers for ttl. Revoked keys keep
+// working for at most that long.
+func NewClient(baseURL, secret string, client *http.Client, ttl time.Duration) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(baseURL, "/") + Path,
+		secret:   secret,
+		client:   client,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[string]entry),
+	}
+}
+
+// VerifyAPIKey implements identity.APIKeyVerifier
+func (c *Client) VerifyAPIKey(ctx context.Context, key string) (*identity.Identity, error) {
+	if !Valid(key) {
+		return nil, identity.ErrInvalidAPIKey
+	}
+
+	hash := Hash(key)
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.cache[hash]
+	c.mu.Unlock()
+	if !ok || now.After(e.expires) {
+		id, err := c.fetch(ctx, key)
+		if err != nil && !errors.Is(err, identity.ErrInvalidAPIKey) {
+			return nil, err
+		}
+		e = entry{id: id, expires: now.Add(c.ttl)}
+		c.mu.Lock()
+		c.prune(now)
+		c.cache[hash] = e
+		c.mu.Unlock()
+	}
+
+	if e.id == nil || (!e.id.ExpiresAt.IsZero() && now.After(e.id.ExpiresAt)) {
+		return nil, identity.ErrInvalidAPIKey
+	}
+	id := *e.id
+	return &id, nil
+}
+
+func (c *Client) fetch(ctx context.Context, key string) (*identity.Identity, error) {
+	body, err := json.Marshal(verifyRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	internalapi.Authorize(req, c.secret)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, identity.ErrInvalidAPIKey
+	default:
+		return nil, fmt.Errorf("api key verification: unexpected status %d", resp.StatusCode)
+	}
+	var id identity.Identity
+	if err := json.NewDecoder(resp.Body).Decode(&id); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// prune drops expired entries at most once per ttl; c.mu must be held
+func (c *Client) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < c.ttl {
+		return
+	}
+	c.lastPrune = now
+	for hash, e := range c.cache {
+		if now.After(e.expires) {
+			delete(c.cache, hash)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package health serves the /health/live and /health/ready endpoints shared
+// by the gateway and the services.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Check reports whether one dependency is usable
+type Check func(ctx context.Context) error
+
+// CheckResult is the readiness of one dependency
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Checker runs the readiness checks of one binary
+type Checker struct {
+	service string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+func New(service string) *Checker {
+	return &Checker{
+		service: service,
+		timeout: 3 * time.Second,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a named readiness check
+func (h *Checker) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Register mounts GET /health/live and GET /health/ready
+func (h *Checker) Register(r gin.IRoutes) {
+	r.GET("/health/live", h.Live)
+	r.GET("/health/ready", h.Ready)
+}
+
+// Live reports that the process is up; it never checks dependencies
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "service": h.service})
+}
+
+// Ready runs every check concurrently and returns 503 if any fails
+func (h *Checker) Ready(c *gin.Context) {
+	results := h.Run(c.Request.Context())
+
+	status, code := "ok", http.StatusOK
+	for _, r := range results {
+		if r.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status":  status,
+		"service": h.service,
+		"checks":  results,
+	})
+}
+
+// Run executes all checks and returns their results by name
+func (h *Checker) Run(ctx context.Context) map[string]CheckResult {
+	checks := h.all()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]CheckResult, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			r := CheckResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				r.Status, r.Error = "fail", err.Error()
+			}
+
+			mu.Lock()
+			results[name] = r
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+func (h *Checker) all() map[string]Check {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	return checks
+}
+
+// Database pings the connection returned by db
+func Database(db func() *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		gdb := db()
+		if gdb == nil {
+			return errors.New("database not connected")
+		}
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// WritableDir verifies that files can be created in dir
+func WritableDir(dir string) Check {
+	return func(ctx context.Context) error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, ".ready-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		f.Close()
+		return os.Remove(name)
+	}
+}
@@ -0,0 +1,72 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API keys let scripts call the services without the owner's password. They
+// are sent in the X-API-Key header or as "Authorization: ApiKey <key>" and
+// verified by the user service.
+const (
+	HeaderAPIKey = "X-API-Key"
+	APIKeyScheme = "ApiKey"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+)
+
+// APIKeyScopes lists every scope a key may carry
+var APIKeyScopes = []string{ScopeProductsRead, ScopeProductsWrite}
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrAPIKeyNotAccepted = errors.New("api keys are not accepted")
+)
+
+// APIKeyVerifier resolves an API key to its owner. The identity carries the
+// key's ID and scopes but no roles or permissions, so a leaked key cannot
+// be used for anything beyond its scopes. Unknown, expired or revoked keys
+// yield ErrInvalidAPIKey.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*Identity, error)
+}
+
+// ValidScope reports whether scope can be granted to an API key
+func ValidScope(scope string) bool {
+	return slices.Contains(APIKeyScopes, scope)
+}
+
+// APIKeyFromRequest returns the API key r was sent with, if any
+func APIKeyFromRequest(r *http.Request) (string, bool) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key, true
+	}
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, APIKeyScheme) && key != "" {
+		return key, true
+	}
+	return "", false
+}
+
+// HasScope reports whether the identity may use scope. Access tokens are
+// not scoped; identities from API keys need the scope on their key.
+func (id *Identity) HasScope(scope string) bool {
+	return id.APIKeyID == 0 || slices.Contains(id.Scopes, scope)
+}
+
+// RequireScope rejects API key callers whose key lacks scope. It must run
+// after Middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return require(func(id *Identity) bool {
+		return id.HasScope(scope)
+	})
+}
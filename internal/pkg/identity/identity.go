@@ -0,0 +1,182 @@
+// Package identity holds the authenticated caller shared by the gateway and
+// the services: JWT parsing, the signed identity headers the gateway forwards
+// after verifying a token, and the gin middleware that accepts either.
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity headers set by the gateway. Clients can never supply them: the
+// gateway strips them from every inbound request.
+const (
+	HeaderUserID        = "X-User-ID"
+	HeaderUsername      = "X-Username"
+	HeaderRoles         = "X-User-Roles"
+	HeaderPermissions   = "X-User-Permissions"
+	HeaderEmailVerified = "X-Email-Verified"
+	HeaderTokenID       = "X-Token-ID"
+	HeaderSessionID     = "X-Session-ID"
+	HeaderTokenVersion  = "X-Token-Version"
+	HeaderTokenExpiry   = "X-Token-Expires"
+	HeaderTimestamp     = "X-Identity-Timestamp"
+	HeaderSignature     = "X-Identity-Signature"
+)
+
+// MaxAge is how long signed identity headers stay valid
+const MaxAge = 60 * time.Second
+
+var (
+	ErrUnsigned         = errors.New("identity headers are not signed")
+	ErrInvalidSignature = errors.New("invalid identity signature")
+	ErrExpired          = errors.New("identity headers expired")
+)
+
+// Identity is the authenticated caller along with the access token it
+// presented, so services can check the token against their revocation list
+type Identity struct {
+	UserID   uint     `json:"user_id"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles"`
+	// Permissions are granted by the roles or individually
+	Permissions []string `json:"permissions"`
+	// EmailVerified is the email_verified claim
+	EmailVerified bool `json:"email_verified"`
+	// APIKeyID is set when the caller authenticated with an API key, which
+	// limits it to Scopes
+	APIKeyID uint     `json:"api_key_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+
+	// TokenID is the token's jti; empty for tokens issued before logout
+	// support existed
+	TokenID string `json:"token_id,omitempty"`
+	// SessionID is the sid claim, the login session the token was issued
+	// for; empty for tokens issued before sessions were tracked
+	SessionID    string    `json:"session_id,omitempty"`
+	TokenVersion uint      `json:"token_version"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// HasRole reports whether the identity carries role
+func (id *Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Strip removes identity headers from h
+func Strip(h http.Header) {
+	for _, name := range []string{
+		HeaderUserID, HeaderUsername, HeaderRoles, HeaderPermissions, HeaderEmailVerified,
+		HeaderTokenID, HeaderSessionID, HeaderTokenVersion, HeaderTokenExpiry,
+		HeaderTimestamp, HeaderSignature,
+	} {
+		h.Del(name)
+	}
+}
+
+// Sign writes id as identity headers for a request to method and path.
+// The signature binds the headers to that request and to the current time.
+func Sign(h http.Header, id *Identity, method, path string, secret []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	roles := strings.Join(id.Roles, ",")
+
+	h.Set(HeaderUserID, strconv.FormatUint(uint64(id.UserID), 10))
+	h.Set(HeaderUsername, id.Username)
+	h.Set(HeaderRoles, roles)
+	h.Set(HeaderPermissions, strings.Join(id.Permissions, ","))
+	h.Set(HeaderEmailVerified, strconv.FormatBool(id.EmailVerified))
+	h.Set(HeaderTokenID, id.TokenID)
+	h.Set(HeaderSessionID, id.SessionID)
+	h.Set(HeaderTokenVersion, strconv.FormatUint(uint64(id.TokenVersion), 10))
+	h.Set(HeaderTokenExpiry, strconv.FormatInt(id.ExpiresAt.Unix(), 10))
+	h.Set(HeaderTimestamp, ts)
+	h.Set(HeaderSignature, signature(secret, signedFields(h, ts, method, path)...))
+}
+
+// Signed reports whether h carries a signed identity
+func Signed(h http.Header) bool {
+	return h.Get(HeaderSignature) != ""
+}
+
+// Verify checks identity headers written by Sign for the given request
+func Verify(h http.Header, method, path string, secret []byte, now time.Time) (*Identity, error) {
+	sig := h.Get(HeaderSignature)
+	if sig == "" {
+		return nil, ErrUnsigned
+	}
+
+	ts := h.Get(HeaderTimestamp)
+	expected := signature(secret, signedFields(h, ts, method, path)...)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return nil, ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > MaxAge || age < -MaxAge {
+		return nil, ErrExpired
+	}
+
+	id, err := strconv.ParseUint(h.Get(HeaderUserID), 10, 64)
+	if err != nil || id == 0 {
+		return nil, ErrInvalidSignature
+	}
+	version, err := strconv.ParseUint(h.Get(HeaderTokenVersion), 10, 32)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	expires, err := strconv.ParseInt(h.Get(HeaderTokenExpiry), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	identity := &Identity{
+		UserID:        uint(id),
+		Username:      h.Get(HeaderUsername),
+		EmailVerified: h.Get(HeaderEmailVerified) == "true",
+		TokenID:       h.Get(HeaderTokenID),
+		SessionID:     h.Get(HeaderSessionID),
+		TokenVersion:  uint(version),
+		ExpiresAt:     time.Unix(expires, 0),
+	}
+	if roles := h.Get(HeaderRoles); roles != "" {
+		identity.Roles = strings.Split(roles, ",")
+	}
+	if perms := h.Get(HeaderPermissions); perms != "" {
+		identity.Permissions = strings.Split(perms, ",")
+	}
+	return identity, nil
+}
+
+// signedFields lists the header values covered by the signature, followed
+// by the timestamp and the request they are bound to
+func signedFields(h http.Header, ts, method, path string) []string {
+	return []string{
+		h.Get(HeaderUserID), h.Get(HeaderUsername), h.Get(HeaderRoles), h.Get(HeaderPermissions), h.Get(HeaderEmailVerified),
+		h.Get(HeaderTokenID), h.Get(HeaderSessionID), h.Get(HeaderTokenVersion), h.Get(HeaderTokenExpiry),
+		ts, method, path,
+	}
+}
+
+func signature(secret []byte, fields ...string) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, f := range fields {
+		fmt.Fprintf(mac, "%d:%s\n", len(f), f)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
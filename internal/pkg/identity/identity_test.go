@@ -0,0 +1,78 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("identity-secret")
+	signedAt := time.Unix(1700000000, 0)
+	id := &Identity{
+		UserID:        42,
+		Username:      "ayse",
+		Roles:         []string{"user", "moderator"},
+		Permissions:   []string{"products:moderate"},
+		EmailVerified: true,
+		TokenID:       "jti-1",
+		SessionID:     "sid-1",
+		TokenVersion:  3,
+		ExpiresAt:     signedAt.Add(15 * time.Minute),
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		secret []byte
+		at     time.Duration // after signing
+		tamper func(h http.Header)
+		err    error
+	}{
+		{name: "valid"},
+		{name: "clock behind the gateway", at: -MaxAge},
+		{name: "at max age", at: MaxAge},
+		{name: "expired", at: MaxAge + time.Second, err: ErrExpired},
+		{name: "from the future", at: -MaxAge - time.Second, err: ErrExpired},
+		{name: "other method", method: http.MethodDelete, err: ErrInvalidSignature},
+		{name: "other path", path: "/products/2", err: ErrInvalidSignature},
+		{name: "other secret", secret: []byte("other"), err: ErrInvalidSignature},
+		{name: "unsigned", tamper: func(h http.Header) { h.Del(HeaderSignature) }, err: ErrUnsigned},
+		{name: "user id changed", tamper: func(h http.Header) { h.Set(HeaderUserID, "1") }, err: ErrInvalidSignature},
+		{name: "role added", tamper: func(h http.Header) { h.Set(HeaderRoles, "user,moderator,admin") }, err: ErrInvalidSignature},
+		{name: "permission added", tamper: func(h http.Header) { h.Set(HeaderPermissions, "users:manage") }, err: ErrInvalidSignature},
+		{name: "token version rolled back", tamper: func(h http.Header) { h.Set(HeaderTokenVersion, "2") }, err: ErrInvalidSignature},
+		{name: "timestamp refreshed", tamper: func(h http.Header) { h.Set(HeaderTimestamp, "1700000100") }, err: ErrInvalidSignature},
+		{name: "email verified forged", tamper: func(h http.Header) { h.Set(HeaderEmailVerified, "true ") }, err: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			Sign(h, id, http.MethodPut, "/products/1", secret, signedAt)
+			if tt.tamper != nil {
+				tt.tamper(h)
+			}
+
+			method, path, key := http.MethodPut, "/products/1", secret
+			if tt.method != "" {
+				method = tt.method
+			}
+			if tt.path != "" {
+				path = tt.path
+			}
+			if tt.secret != nil {
+				key = tt.secret
+			}
+			got, err := Verify(h, method, path, key, signedAt.Add(tt.at))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify: %v, want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(got, id) {
+				t.Fatalf("Verify: %+v, want %+v", got, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin context key holding the *Identity
+const ContextKey = "identity"
+
+var (
+	ErrMissingAuthorization = errors.New("authorization header required")
+	ErrBadAuthorization     = errors.New("malformed authorization header")
+	ErrRevoked              = errors.New("token revoked")
+)
+
+// RevocationChecker reports whether the token an identity was
+// authenticated with has been revoked
+type RevocationChecker interface {
+	Revoked(id *Identity) bool
+}
+
+// Options configure Authenticate
+type Options struct {
+	// Keys verifies bearer tokens when the service runs standalone
+	Keys Keys
+	// IdentitySecret verifies identity headers signed by the gateway; empty
+	// disables them so a service never trusts unsigned headers
+	IdentitySecret string
+	// Revocations rejects logged out tokens; nil disables the check
+	Revocations RevocationChecker
+	// APIKeys verifies API keys; nil rejects them
+	APIKeys APIKeyVerifier
+}
+
+// Authenticate resolves the caller from gateway identity headers when they
+// are present and trusted, otherwise from the API key or bearer token.
+func Authenticate(r *http.Request, opts Options) (*Identity, error) {
+	if opts.IdentitySecret != "" && Signed(r.Header) {
+		id, err := Verify(r.Header, r.Method, r.URL.Path, []byte(opts.IdentitySecret), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return checkRevoked(id, opts)
+	}
+
+	// API keys are revoked in the user service, which checks them on every
+	// verification
+	if key, ok := APIKeyFromRequest(r); ok {
+		if opts.APIKeys == nil {
+			return nil, ErrAPIKeyNotAccepted
+		}
+		return opts.APIKeys.VerifyAPIKey(r.Context(), key)
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, ErrMissingAuthorization
+	}
+
+	tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok {
+		return nil, ErrBadAuthorization
+	}
+	id, err := ParseToken(r.Context(), tokenString, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+	return checkRevoked(id, opts)
+}
+
+func checkRevoked(id *Identity, opts Options) (*Identity, error) {
+	if opts.Revocations != nil && opts.Revocations.Revoked(id) {
+		return nil, ErrRevoked
+	}
+	return id, nil
+}
+
+// Middleware authenticates the request and stores the identity (and its
+// user ID under "user_id") in the gin context. It does not call c.Next so
+// services can wrap it with their own checks.
+func Middleware(opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := Authenticate(c.Request, opts)
+		if err != nil {
+			response.Error(c, http.StatusUnauthorized, errorMessage(err))
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextKey, id)
+		c.Set("user_id", id.UserID)
+	}
+}
+
+// FromContext returns the identity stored by Middleware
+func FromContext(c *gin.Context) *Identity {
+	if v, ok := c.Get(ContextKey); ok {
+		if id, ok := v.(*Identity); ok {
+			return id
+		}
+	}
+	return nil
+}
+
+func errorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingAuthorization):
+		return "Authorization header gerekli"
+	case errors.Is(err, ErrBadAuthorization):
+		return "Geçersiz token formatı"
+	case errors.Is(err, ErrInvalidClaims):
+		return "Geçersiz user ID"
+	case errors.Is(err, ErrRevoked):
+		return "Oturum sonlandırılmış"
+	case errors.Is(err, ErrInvalidAPIKey):
+		return "Geçersiz API anahtarı"
+	case errors.Is(err, ErrAPIKeyNotAccepted):
+		return "API anahtarı bu endpoint için kullanılamaz"
+	default:
+		return "Geçersiz token"
+	}
+}
@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"net/http"
+	"slices"
+
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permissions
+const (
+	PermUsersRead        = "users:read"
+	PermUsersManage      = "users:manage"
+	PermProductsModerate = "products:moderate"
+)
+
+// RolePermissions lists what each role may do. The user service embeds the
+// resulting permissions in access tokens, so every service enforces the
+// same mapping without a lookup.
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermUsersRead, PermProductsModerate},
+	RoleAdmin:     {PermUsersRead, PermUsersManage, PermProductsModerate},
+}
+
+// ValidRole reports whether role is known
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// Permissions returns the permissions granted by roles plus the extra
+// grants, sorted and without duplicates
+func Permissions(roles, extra []string) []string {
+	perms := append([]string{}, extra...)
+	for _, role := range roles {
+		perms = append(perms, RolePermissions[role]...)
+	}
+	slices.Sort(perms)
+	return slices.Compact(perms)
+}
+
+// HasPermission reports whether the identity carries perm
+func (id *Identity) HasPermission(perm string) bool {
+	return slices.Contains(id.Permissions, perm)
+}
+
+// RequireRole rejects callers that have none of roles. It must run after
+// Middleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return require(func(id *Identity) bool {
+		return slices.ContainsFunc(roles, id.HasRole)
+	})
+}
+
+// RequirePermission rejects callers lacking any of perms. It must run after
+// Middleware.
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return require(func(id *Identity) bool {
+		for _, perm := range perms {
+			if !id.HasPermission(perm) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func require(allowed func(*Identity) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := FromContext(c)
+		if id == nil {
+			response.Error(c, http.StatusUnauthorized, "Authorization header gerekli")
+			c.Abort()
+			return
+		}
+		if !allowed(id) {
+			response.Error(c, http.StatusForbidden, "Bu işlem için yetkiniz yok")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
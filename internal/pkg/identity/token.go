@@ -0,0 +1,92 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"time"
+
+	"enchanted-micro/internal/pkg/jwks"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrInvalidClaims = errors.New("invalid token claims")
+)
+
+// Keys resolves the public key and algorithm for a token's kid header
+type Keys interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, string, error)
+}
+
+// Algorithms are the only signing algorithms accepted for access tokens
+var Algorithms = []string{jwks.AlgRS256, jwks.AlgEdDSA}
+
+// ParseToken verifies an access token against keys and returns its
+// identity. The token's alg must be one of Algorithms and must match the
+// algorithm its key was published with.
+func ParseToken(ctx context.Context, tokenString string, keys Keys) (*Identity, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" || keys == nil {
+			return nil, ErrInvalidToken
+		}
+		pub, alg, err := keys.PublicKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != alg {
+			return nil, ErrInvalidToken
+		}
+		return pub, nil
+	}, jwt.WithValidMethods(Algorithms), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidClaims
+	}
+	return FromClaims(claims)
+}
+
+// FromClaims extracts the identity from access token claims
+func FromClaims(claims jwt.MapClaims) (*Identity, error) {
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID < 1 {
+		return nil, ErrInvalidClaims
+	}
+
+	id := &Identity{UserID: uint(userID)}
+	id.Username, _ = claims["username"].(string)
+	id.EmailVerified, _ = claims["email_verified"].(bool)
+	id.TokenID, _ = claims["jti"].(string)
+	id.SessionID, _ = claims["sid"].(string)
+	if ver, ok := claims["ver"].(float64); ok && ver > 0 {
+		id.TokenVersion = uint(ver)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		id.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	id.Roles = stringList(claims["roles"])
+	id.Permissions = stringList(claims["perms"])
+	return id, nil
+}
+
+// stringList converts a JSON array claim to strings, skipping other values
+func stringList(claim interface{}) []string {
+	values, ok := claim.([]interface{})
+	if !ok {
+		return nil
+	}
+	var list []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
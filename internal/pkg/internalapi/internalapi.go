@@ -0,0 +1,39 @@
+// Package internalapi guards the service-to-service endpoints under
+// /internal with a shared secret. The gateway strips the header from client
+// requests, so only the services themselves can present it.
+package internalapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the shared INTERNAL_API_SECRET
+const Header = "X-Internal-Token"
+
+// Middleware rejects requests that do not present secret. With an empty
+// secret the internal endpoints are disabled entirely.
+func Middleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			response.Error(c, http.StatusNotFound, "Not found")
+			c.Abort()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(Header)), []byte(secret)) != 1 {
+			response.Error(c, http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize adds the shared secret to an outgoing internal request
+func Authorize(req *http.Request, secret string) {
+	req.Header.Set(Header, secret)
+}
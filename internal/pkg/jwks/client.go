@@ -0,0 +1,146 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minRefreshInterval limits refetches triggered by unknown key IDs, so
+// tokens with made-up kids cannot hammer the user service
+const minRefreshInterval = 10 * time.Second
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrNoKeys     = errors.New("no signing keys fetched")
+)
+
+// Client fetches a key set over HTTP and caches it for CacheTTL. A token
+// signed with a key that is not cached triggers an early refetch, which is
+// how verifiers pick up a rotated key. When the user service cannot be
+// reached the last fetched keys keep being used.
+type Client struct {
+	url  string
+	http *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]Key
+	fetchedAt time.Time
+
+	// refreshMu serialises fetches; attemptedAt is guarded by it
+	refreshMu   sync.Mutex
+	attemptedAt time.Time
+	// refreshing is set while a background refresh is in flight, so stale
+	// lookups do not pile up goroutines waiting on refreshMu
+	refreshing atomic.Bool
+}
+
+// NewClient builds a client for the key set at url
+func NewClient(url string, httpClient *http.Client) *Client {
+	return &Client{url: url, http: httpClient, keys: make(map[string]Key)}
+}
+
+// PublicKey returns the key with the given ID and its algorithm. Stale
+// keys are refreshed in the background; unknown ones synchronously.
+func (c *Client) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, string, error) {
+	key, ok, stale := c.lookup(kid)
+	if ok {
+		if stale && c.refreshing.CompareAndSwap(false, true) {
+			go func() {
+				defer c.refreshing.Store(false)
+				c.refresh(context.Background())
+			}()
+		}
+		return key.Public, key.Algorithm, nil
+	}
+
+	c.refresh(ctx)
+	if key, ok, _ = c.lookup(kid); !ok {
+		return nil, "", ErrUnknownKey
+	}
+	return key.Public, key.Algorithm, nil
+}
+
+// Ready reports whether a key set has been fetched; it can be used as a
+// readiness check
+func (c *Client) Ready(ctx context.Context) error {
+	c.mu.RLock()
+	n := len(c.keys)
+	c.mu.RUnlock()
+	if n > 0 {
+		return nil
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.keys) == 0 {
+		return ErrNoKeys
+	}
+	return nil
+}
+
+func (c *Client) lookup(kid string) (Key, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.keys[kid]
+	return key, ok, time.Since(c.fetchedAt) > CacheTTL
+}
+
+// refresh refetches the key set unless another fetch happened within
+// minRefreshInterval
+func (c *Client) refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if time.Since(c.attemptedAt) < minRefreshInterval {
+		return nil
+	}
+	c.attemptedAt = time.Now()
+
+	keys, err := c.fetch(ctx)
+	if err != nil {
+		slog.Warn("jwks refresh failed", "url", c.url, "error", err)
+		return err
+	}
+
+	next := make(map[string]Key, len(keys))
+	for _, k := range keys {
+		next[k.ID] = k
+	}
+	c.mu.Lock()
+	c.keys = next
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) fetch(ctx context.Context) ([]Key, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(body)
+}
@@ -0,0 +1,176 @@
+// Package jwks encodes and fetches JSON Web Key Sets (RFC 7517) holding the
+// public keys the user service signs access tokens with.
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Path is where the user service publishes its key set
+const Path = "/.well-known/jwks.json"
+
+// CacheTTL is how long verifiers may cache the key set. New keys are
+// published at least this long before they start signing.
+const CacheTTL = 5 * time.Minute
+
+var ErrUnsupportedKey = errors.New("unsupported key type")
+
+// Key is a public verification key
+type Key struct {
+	ID        string
+	Algorithm string
+	Public    crypto.PublicKey
+}
+
+// jsonKey is the wire format of a single JWK
+type jsonKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type keySet struct {
+	Keys []jsonKey `json:"keys"`
+}
+
+// Algorithm returns the signing algorithm used with a public key
+func Algorithm(pub crypto.PublicKey) (string, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return AlgRS256, nil
+	case ed25519.PublicKey:
+		return AlgEdDSA, nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+// Marshal encodes keys as a JWK set
+func Marshal(keys []Key) ([]byte, error) {
+	set := keySet{Keys: make([]jsonKey, 0, len(keys))}
+	for _, k := range keys {
+		jk := jsonKey{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			jk.Kty = "RSA"
+			jk.N = encode(pub.N.Bytes())
+			jk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jk.Kty = "OKP"
+			jk.Crv = "Ed25519"
+			jk.X = encode(pub)
+		default:
+			return nil, fmt.Errorf("key %s: %w", k.ID, ErrUnsupportedKey)
+		}
+		set.Keys = append(set.Keys, jk)
+	}
+	return json.Marshal(set)
+}
+
+// Parse decodes a JWK set. Keys of unsupported types or with an algorithm
+// that does not match their type are skipped.
+func Parse(data []byte) ([]Key, error) {
+	var set keySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, jk := range set.Keys {
+		if jk.Kid == "" || (jk.Use != "" && jk.Use != "sig") {
+			continue
+		}
+		pub, err := jk.publicKey()
+		if err != nil {
+			continue
+		}
+		if alg, _ := Algorithm(pub); alg != jk.Alg {
+			continue
+		}
+		keys = append(keys, Key{ID: jk.Kid, Algorithm: jk.Alg, Public: pub})
+	}
+	return keys, nil
+}
+
+func (jk jsonKey) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case jk.Kty == "RSA":
+		n, err := decode(jk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jk.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, ErrUnsupportedKey
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, ErrUnsupportedKey
+		}
+		return pub, nil
+	case jk.Kty == "OKP" && jk.Crv == "Ed25519":
+		x, err := decode(jk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// Handler serves the keys returned by source as a JWK set
+func Handler(source func() ([]Key, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := source()
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "keys unavailable")
+			return
+		}
+		body, err := Marshal(keys)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "keys unavailable")
+			return
+		}
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(CacheTTL/time.Second)))
+		c.Data(http.StatusOK, "application/json", body)
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
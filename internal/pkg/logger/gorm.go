@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is the threshold above which queries are logged as warnings
+const slowQuery = 200 * time.Millisecond
+
+// Gorm adapts slog to gorm's logger. Failed queries are logged as errors,
+// slow ones as warnings and every query at debug level.
+type Gorm struct {
+	level gormlogger.LogLevel
+}
+
+func NewGorm() *Gorm {
+	return &Gorm{level: gormlogger.Warn}
+}
+
+func (g *Gorm) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &Gorm{level: level}
+}
+
+func (g *Gorm) Info(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).InfoContext(ctx, msg, "args", args)
+}
+
+func (g *Gorm) Warn(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).WarnContext(ctx, msg, "args", args)
+}
+
+func (g *Gorm) Error(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).ErrorContext(ctx, msg, "args", args)
+}
+
+func (g *Gorm) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level == gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	l := FromContext(ctx)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		l.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case elapsed > slowQuery:
+		sql, rows := fc()
+		l.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case l.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		l.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,107 @@
+// Package logger configures the slog logger shared by all binaries and
+// provides the gin access log middleware.
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Init builds the process logger from level ("debug", "info", "warn",
+// "error") and format ("json" or "text"), tags it with the service name and
+// installs it as the slog and log package default.
+func Init(service, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+
+	l := slog.New(handler).With("service", service)
+	slog.SetDefault(l)
+	return l
+}
+
+// ParseLevel maps a level name to a slog level, defaulting to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Fatal logs at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// FromContext returns the default logger annotated with the request ID and
+// trace ID carried by ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if id := requestid.FromContext(ctx); id != "" {
+		l = l.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		l = l.With("trace_id", sc.TraceID().String())
+	}
+	return l
+}
+
+// FromGin returns the logger for a gin request
+func FromGin(c *gin.Context) *slog.Logger {
+	return FromContext(c.Request.Context())
+}
+
+// Middleware writes one access log line per request with the request ID,
+// user ID, route template, status and latency
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", metrics.Route(c),
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case c.Writer.Status() >= 500:
+			level = slog.LevelError
+		case c.Writer.Status() >= 400:
+			level = slog.LevelWarn
+		}
+		FromGin(c).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
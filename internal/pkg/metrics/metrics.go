@@ -0,0 +1,68 @@
+// Package metrics exposes Prometheus metrics shared by the gateway and the
+// services: HTTP request histograms, GORM query timings and connection
+// pool stats.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RouteKey is the gin context key a handler can set to override the route
+// label, e.g. the gateway sets it to the matched route table entry because
+// all of its traffic goes through NoRoute
+const RouteKey = "route"
+
+var (
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+)
+
+// Middleware records the latency and status of every request. Routes are
+// labelled by template (/products/:id) rather than raw path so the label
+// set stays bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		c.Next()
+
+		httpDuration.WithLabelValues(
+			c.Request.Method,
+			Route(c),
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Route returns the label for the request's route: the value stored under
+// RouteKey, else the gin route template, else "unmatched"
+func Route(c *gin.Context) string {
+	if route := c.GetString(RouteKey); route != "" {
+		return route
+	}
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// Register serves the default registry on GET /metrics
+func Register(r gin.IRoutes) {
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
@@ -0,0 +1,27 @@
+// Package randtoken generates the opaque single-use tokens the user service
+// hands out (refresh, reset, verification, MFA and SSO tokens) and the hash
+// under which they are stored, so a database leak does not leak usable
+// tokens.
+package randtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// New returns 32 random bytes encoded for use in URLs
+func New() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the stored form of token
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
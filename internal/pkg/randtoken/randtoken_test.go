@@ -0,0 +1,32 @@
+package randtoken
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("tokens repeat")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(a)
+	if err != nil || len(raw) != 32 {
+		t.Fatalf("token %q decodes to %d bytes, %v", a, len(raw), err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	// sha256("abc")
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := Hash("abc"); got != want {
+		t.Fatalf("Hash %s, want %s", got, want)
+	}
+}
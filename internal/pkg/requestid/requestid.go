@@ -0,0 +1,71 @@
+// Package requestid assigns every request an X-Request-ID, accepting the one
+// sent by the caller (normally the gateway) when it looks sane.
+package requestid
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID between clients, the gateway and services
+const Header = "X-Request-ID"
+
+// ContextKey is the gin context key holding the request ID
+const ContextKey = "request_id"
+
+// maxLength bounds accepted IDs so clients cannot bloat every log line
+const maxLength = 128
+
+type ctxKey struct{}
+
+// Middleware reads or generates the request ID, stores it in the gin and
+// request contexts, sets it on the inbound request headers (so proxied
+// requests carry it) and echoes it on the response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !valid(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(ContextKey, id)
+		c.Request.Header.Set(Header, id)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), id))
+		c.Header(Header, id)
+
+		c.Next()
+	}
+}
+
+// Get returns the request ID of a gin request
+func Get(c *gin.Context) string {
+	return c.GetString(ContextKey)
+}
+
+// NewContext returns ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, if any
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
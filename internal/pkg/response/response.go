@@ -0,0 +1,18 @@
+// Package response writes the JSON error bodies shared by all binaries.
+package response
+
+import (
+	"enchanted-micro/internal/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error writes {"error": message, "request_id": ...} so a failed request can
+// be matched with the log lines of every service it went through.
+func Error(c *gin.Context, status int, message string) {
+	body := gin.H{"error": message}
+	if id := requestid.Get(c); id != "" {
+		body["request_id"] = id
+	}
+	c.JSON(status, body)
+}
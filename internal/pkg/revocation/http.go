@@ -0,0 +1,74 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path is where the user service serves revocation deltas
+const Path = "/internal/revocations"
+
+// Handler serves source as JSON; the cursor is passed as ?since=RFC3339
+func Handler(source Source) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var since time.Time
+		if v := c.Query("since"); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				response.Error(c, http.StatusBadRequest, "invalid since parameter")
+				return
+			}
+			since = t
+		}
+
+		delta, err := source(c.Request.Context(), since)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "revocations unavailable")
+			return
+		}
+		c.JSON(http.StatusOK, delta)
+	}
+}
+
+// HTTPSource reads deltas from the user service at baseURL
+func HTTPSource(baseURL, secret string, client *http.Client) Source {
+	endpoint := strings.TrimSuffix(baseURL, "/") + Path
+
+	return func(ctx context.Context, since time.Time) (*Delta, error) {
+		u := endpoint
+		if !since.IsZero() {
+			u += "?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339Nano))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		internalapi.Authorize(req, secret)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("revocations: unexpected status %d", resp.StatusCode)
+		}
+		var delta Delta
+		if err := json.NewDecoder(resp.Body).Decode(&delta); err != nil {
+			return nil, err
+		}
+		return &delta, nil
+	}
+}
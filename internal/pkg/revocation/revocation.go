@@ -0,0 +1,180 @@
+// Package revocation keeps an in-memory copy of revoked access tokens and
+// sessions so auth middleware can reject logged out tokens without a
+// database or network round trip per request. The cache is filled
+// incrementally from a Source: the user service reads its own database,
+// other services poll the user service's internal endpoint.
+package revocation
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+)
+
+// Token is a single revoked access token, kept until it expires on its own
+type Token struct {
+	ID        string    `json:"id"`
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserVersion invalidates every token of a user issued with a lower version
+type UserVersion struct {
+	UserID  uint `json:"user_id"`
+	Version uint `json:"version"`
+}
+
+// Session is a revoked login session. It is kept until the last access
+// token issued for it has expired.
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Delta holds the revocations recorded since a point in time. Until is the
+// source's clock when the delta was read and becomes the next cursor.
+type Delta struct {
+	Tokens   []Token       `json:"tokens"`
+	Users    []UserVersion `json:"users"`
+	Sessions []Session     `json:"sessions"`
+	Until    time.Time     `json:"until"`
+}
+
+// Source returns the revocations recorded at or after since
+type Source func(ctx context.Context, since time.Time) (*Delta, error)
+
+// overlap re-reads a short window on every sync so rows committed by slow
+// transactions with an earlier timestamp are not missed
+const overlap = 30 * time.Second
+
+// Cache answers revocation checks from memory
+type Cache struct {
+	source Source
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	sessions map[string]time.Time
+	versions map[uint]uint
+	since    time.Time
+}
+
+// NewCache builds an empty cache filled by source
+func NewCache(source Source) *Cache {
+	return &Cache{
+		source:   source,
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		versions: make(map[uint]uint),
+	}
+}
+
+// Revoked implements identity.RevocationChecker
+func (c *Cache) Revoked(id *identity.Identity) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if id.TokenVersion < c.versions[id.UserID] {
+		return true
+	}
+	if id.SessionID != "" {
+		if _, ok := c.sessions[id.SessionID]; ok {
+			return true
+		}
+	}
+	if id.TokenID == "" {
+		return false
+	}
+	_, ok := c.tokens[id.TokenID]
+	return ok
+}
+
+// Add records revocations immediately, e.g. right after a logout on the
+// instance that handled it
+func (c *Cache) Add(tokens []Token, users []UserVersion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apply(tokens, users, nil)
+}
+
+// AddSessions records revoked sessions immediately
+func (c *Cache) AddSessions(sessions []Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apply(nil, nil, sessions)
+}
+
+// Sync pulls the revocations recorded since the last sync
+func (c *Cache) Sync(ctx context.Context) error {
+	c.mu.RLock()
+	since := c.since
+	c.mu.RUnlock()
+
+	delta, err := c.source(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apply(delta.Tokens, delta.Users, delta.Sessions)
+	c.since = delta.Until.Add(-overlap)
+	return nil
+}
+
+// Run syncs every interval and prunes expired tokens until ctx is done.
+// Failed syncs are logged and the previous state stays in use.
+func (c *Cache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Sync(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("revocation sync failed", "error", err)
+		}
+		c.prune(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cache) apply(tokens []Token, users []UserVersion, sessions []Session) {
+	now := time.Now()
+	for _, t := range tokens {
+		if t.ExpiresAt.After(now) {
+			c.tokens[t.ID] = t.ExpiresAt
+		}
+	}
+	for _, s := range sessions {
+		if s.ExpiresAt.After(now) {
+			c.sessions[s.ID] = s.ExpiresAt
+		}
+	}
+	for _, u := range users {
+		if u.Version > c.versions[u.UserID] {
+			c.versions[u.UserID] = u.Version
+		}
+	}
+}
+
+func (c *Cache) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, expires := range c.tokens {
+		if !expires.After(now) {
+			delete(c.tokens, id)
+		}
+	}
+	for id, expires := range c.sessions {
+		if !expires.After(now) {
+			delete(c.sessions, id)
+		}
+	}
+}
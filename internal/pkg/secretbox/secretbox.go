@@ -0,0 +1,78 @@
+// Package secretbox encrypts small secrets (signing keys, TOTP seeds) before
+// they are written to the database, using AES-256-GCM with a key derived
+// from DATA_ENCRYPTION_KEY.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// prefix marks sealed values so plaintext written before encryption was
+// configured can still be read
+const prefix = "v1:"
+
+var ErrDecrypt = errors.New("secretbox: cannot decrypt value")
+
+// Box seals and opens values. A nil *Box stores values as plaintext.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a box keyed by secret, or nil when secret is empty
+func New(secret string) *Box {
+	if secret == "" {
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // a 32 byte key is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Box{aead: aead}
+}
+
+// Seal encrypts plaintext
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	if b == nil {
+		return string(plaintext), nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value written by Seal. Unsealed values are returned as
+// they are.
+func (b *Box) Open(value string) ([]byte, error) {
+	encoded, sealed := strings.CutPrefix(value, prefix)
+	if !sealed {
+		return []byte(value), nil
+	}
+	if b == nil {
+		return nil, ErrDecrypt
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
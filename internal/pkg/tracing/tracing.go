@@ -0,0 +1,78 @@
+// Package tracing configures OpenTelemetry for the gateway and services.
+// Spans are propagated between binaries with the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporter names accepted by Init
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Init installs a global tracer provider for service that sends spans to
+// the named exporter. The OTLP exporter is configured through the standard
+// OTEL_EXPORTER_OTLP_* variables and sampling through OTEL_TRACES_SAMPLER.
+// With ExporterNone only context propagation is installed, so trace IDs
+// still flow through to upstream services.
+func Init(ctx context.Context, service, exporter string) (func(context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+
+	switch strings.ToLower(exporter) {
+	case "", ExporterNone:
+		setPropagator()
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tp := Setup(service, sdktrace.WithBatcher(exp))
+	return tp.Shutdown, nil
+}
+
+// Setup installs a global tracer provider for service with the given
+// options and returns it. Tests pass sdktrace.WithSyncer with a
+// tracetest.InMemoryExporter to inspect the recorded spans.
+func Setup(service string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(service),
+	))
+	if err != nil {
+		res = resource.Default()
+	}
+
+	tp := sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+	otel.SetTracerProvider(tp)
+	setPropagator()
+	return tp
+}
+
+func setPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
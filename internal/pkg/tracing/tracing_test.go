@@ -0,0 +1,103 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"enchanted-micro/internal/gateway/proxy"
+	"enchanted-micro/internal/gateway/routes"
+	"enchanted-micro/internal/gateway/upstream"
+	"enchanted-micro/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestGatewayToServicePropagation sends a request through the gateway proxy
+// to a service and checks that both record spans of one trace, linked via
+// the traceparent header
+func TestGatewayToServicePropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.Setup("test", sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	var traceparent string
+	service := gin.New()
+	service.Use(otelgin.Middleware("product-service"))
+	service.GET("/products", func(c *gin.Context) {
+		traceparent = c.GetHeader("traceparent")
+		c.Status(http.StatusOK)
+	})
+	backend := httptest.NewServer(service)
+	defer backend.Close()
+
+	table, err := routes.Parse([]byte(`
+upstreams:
+  product:
+    url: `+backend.URL+`
+    health_check:
+      disabled: true
+routes:
+  - name: products
+    prefix: /products
+    upstream: product
+`), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := upstream.NewPool("product", table.Upstreams["product"])
+	p := proxy.New(http.DefaultTransport)
+
+	gateway := gin.New()
+	gateway.Use(otelgin.Middleware("gin-gateway"))
+	gateway.NoRoute(func(c *gin.Context) { p.Serve(c, pool, "", proxy.Policy{}) })
+	front := httptest.NewServer(gateway)
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if traceparent == "" {
+		t.Fatal("service got no traceparent header")
+	}
+
+	spans := exporter.GetSpans()
+	byKind := map[trace.SpanKind][]tracetest.SpanStub{}
+	for _, s := range spans {
+		byKind[s.SpanKind] = append(byKind[s.SpanKind], s)
+	}
+	// gateway server span, proxy client span, service server span
+	if len(byKind[trace.SpanKindServer]) != 2 || len(byKind[trace.SpanKindClient]) != 1 {
+		t.Fatalf("got %d spans: %+v", len(spans), byKind)
+	}
+
+	client := byKind[trace.SpanKindClient][0]
+	var gatewaySpan, serviceSpan tracetest.SpanStub
+	for _, s := range byKind[trace.SpanKindServer] {
+		if s.Parent.IsValid() && s.Parent.SpanID() == client.SpanContext.SpanID() {
+			serviceSpan = s
+		} else {
+			gatewaySpan = s
+		}
+	}
+	if !serviceSpan.SpanContext.IsValid() {
+		t.Fatal("service span is not a child of the proxy's client span")
+	}
+	if client.Parent.SpanID() != gatewaySpan.SpanContext.SpanID() {
+		t.Fatal("client span is not a child of the gateway span")
+	}
+	traceID := gatewaySpan.SpanContext.TraceID()
+	if client.SpanContext.TraceID() != traceID || serviceSpan.SpanContext.TraceID() != traceID {
+		t.Fatal("spans belong to different traces")
+	}
+}
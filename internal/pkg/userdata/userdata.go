@@ -0,0 +1,153 @@
+// Package userdata lets the user service collect and erase what other
+// services store about a user, for data exports and account deletion.
+// Services serve an export and a delete endpoint under /internal; the user
+// service calls them through a Client.
+package userdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"enchanted-micro/internal/pkg/internalapi"
+	"enchanted-micro/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path is where services serve a user's data: GET exports, DELETE erases
+const Path = "/internal/users/:id/data"
+
+// Export is what a service stores about a user
+type Export struct {
+	// Data is written to the archive as is
+	Data json.RawMessage `json:"data"`
+	// Files are paths on the service the user uploaded, e.g. images; they
+	// are fetched with Client.Open
+	Files []string `json:"files"`
+}
+
+// Exporter returns what the service stores about userID
+type Exporter func(ctx context.Context, userID uint) (*Export, error)
+
+// Eraser removes or anonymizes what the service stores about userID. It
+// must succeed when there is nothing left to erase, since deletions are
+// retried.
+type Eraser func(ctx context.Context, userID uint) error
+
+// ExportHandler serves export as JSON
+func ExportHandler(export Exporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userParam(c)
+		if !ok {
+			return
+		}
+		data, err := export(c.Request.Context(), userID)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "user data export failed")
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// EraseHandler runs erase and answers 204
+func EraseHandler(erase Eraser) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userParam(c)
+		if !ok {
+			return
+		}
+		if err := erase(c.Request.Context(), userID); err != nil {
+			response.Error(c, http.StatusInternalServerError, "user data could not be erased")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func userParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		response.Error(c, http.StatusBadRequest, "invalid user id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// Client calls the endpoints of the service at baseURL
+type Client struct {
+	baseURL string
+	secret  string
+	client  *http.Client
+}
+
+// NewClient returns a client authorized with the internal API secret
+func NewClient(baseURL, secret string, client *http.Client) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), secret: secret, client: client}
+}
+
+// Export fetches what the service stores about userID
+func (c *Client) Export(ctx context.Context, userID uint) (*Export, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.userPath(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user data export: unexpected status %d", resp.StatusCode)
+	}
+	var export Export
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// Erase asks the service to remove what it stores about userID
+func (c *Client) Erase(ctx context.Context, userID uint) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.userPath(userID))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("user data erase: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Open downloads one of the files of an Export; the caller closes it
+func (c *Client) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("user data file %q: not a path", path)
+	}
+	resp, err := c.do(ctx, http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("user data file %q: unexpected status %d", path, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) userPath(userID uint) string {
+	return strings.Replace(Path, ":id", strconv.FormatUint(uint64(userID), 10), 1)
+}
+
+func (c *Client) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	internalapi.Authorize(req, c.secret)
+	return c.client.Do(req)
+}
@@ -1,39 +1,72 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	JWTSecret  string
-	Port       string
-	UploadPath string
+	DBHost         string
+	DBPort         string
+	DBUser         string
+	DBPassword     string
+	DBName         string
+	IdentitySecret string
+	// Token imzaları user service'in yayınladığı anahtarlarla doğrulanır
+	JWKSURL string
+	// E-postası doğrulanmamış kullanıcılar ilan oluşturamaz
+	RequireVerifiedEmail bool
+	// Çıkış yapılmış token listesi user service'ten çekilir
+	UserServiceURL         string
+	InternalAPISecret      string
+	RevocationSyncInterval time.Duration
+	// API anahtarı doğrulamaları bu süre önbellekte tutulur; iptal edilen
+	// anahtarlar en fazla bu kadar geçerli kalır
+	APIKeyCacheTTL time.Duration
+	// Satıcı profilleri bu süre önbellekte tutulur
+	SellerCacheTTL time.Duration
+	// X-Forwarded-For yalnızca bu adreslerden (gateway) gelirse dikkate
+	// alınır; boşsa istemci IP'si bağlantının adresidir
+	TrustedProxies []string
+	LogLevel       string
+	LogFormat      string
+	TraceExporter  string
+	Port           string
+	UploadPath     string
 }
 
 func LoadConfig() *Config {
 	// config.env dosyasını yükle
 	err := godotenv.Load("config.env")
 	if err != nil {
-		log.Println("config.env dosyası bulunamadı, sistem değişkenlerini kullanıyor")
+		slog.Info("config.env not found, using environment variables")
 	}
 
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:     getEnv("PRODUCT_DB_NAME", "octopusproductdb"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-super-secret-jwt-key-here"),
-		Port:       getEnv("PRODUCT_PORT", "8081"),
-		UploadPath: getEnv("UPLOAD_PATH", "./uploads"),
+		DBHost:                 getEnv("DB_HOST", "localhost"),
+		DBPort:                 getEnv("DB_PORT", "5432"),
+		DBUser:                 getEnv("DB_USER", "postgres"),
+		DBPassword:             getEnv("DB_PASSWORD", "postgres"),
+		DBName:                 getEnv("PRODUCT_DB_NAME", "octopusproductdb"),
+		IdentitySecret:         getEnv("IDENTITY_SECRET", ""),
+		JWKSURL:                getEnv("JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		RequireVerifiedEmail:   getBool("REQUIRE_VERIFIED_EMAIL", false),
+		UserServiceURL:         getEnv("USER_SERVICE_URL", "http://localhost:8080"),
+		InternalAPISecret:      getEnv("INTERNAL_API_SECRET", ""),
+		RevocationSyncInterval: getDuration("REVOCATION_SYNC_INTERVAL", 5*time.Second),
+		APIKeyCacheTTL:         getDuration("API_KEY_CACHE_TTL", 30*time.Second),
+		SellerCacheTTL:         getDuration("SELLER_CACHE_TTL", 5*time.Minute),
+		TrustedProxies:         getList("TRUSTED_PROXIES"),
+		Port:                   getEnv("PRODUCT_PORT", "8081"),
+		LogLevel:               getEnv("LOG_LEVEL", "info"),
+		LogFormat:              getEnv("LOG_FORMAT", "json"),
+		TraceExporter:          getEnv("TRACE_EXPORTER", "none"),
+		UploadPath:             getEnv("UPLOAD_PATH", "./uploads"),
 	}
 }
 
@@ -43,3 +76,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using default", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
+
+func getBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
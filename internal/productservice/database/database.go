@@ -2,8 +2,11 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/tracing"
 	"enchanted-micro/internal/productservice/config"
 	"enchanted-micro/internal/productservice/models"
 
@@ -18,20 +21,27 @@ func ConnectDB(cfg *config.Config) {
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.NewGorm()})
 	if err != nil {
-		log.Fatal("Veritabanına bağlanılamadı:", err)
+		logger.Fatal("database connection failed", "error", err)
 	}
 
-	log.Println("PostgreSQL veritabanına başarıyla bağlanıldı!")
+	slog.Info("database connected", "host", cfg.DBHost, "database", cfg.DBName)
+
+	if err := metrics.InstrumentGorm(DB, cfg.DBName); err != nil {
+		slog.Warn("database metrics disabled", "error", err)
+	}
+	if err := tracing.InstrumentGorm(DB); err != nil {
+		slog.Warn("database tracing disabled", "error", err)
+	}
 
 	// Auto migrate
 	err = DB.AutoMigrate(&models.Product{})
 	if err != nil {
-		log.Fatal("Migration hatası:", err)
+		logger.Fatal("database migration failed", "error", err)
 	}
 
-	log.Println("Veritabanı tabloları oluşturuldu!")
+	slog.Info("database migrated")
 }
 
 func GetDB() *gorm.DB {
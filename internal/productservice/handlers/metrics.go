@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// uploadedBytes - Kaydedilen resim dosyalarının toplam boyutu
+	uploadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "product_image_upload_bytes_total",
+		Help: "Bytes of product images written to the upload directory.",
+	})
+
+	// uploads - Resim yükleme istekleri (success / failure)
+	uploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "product_image_uploads_total",
+		Help: "Product image uploads by result.",
+	}, []string{"result"})
+)
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/productservice/database"
+	"enchanted-micro/internal/productservice/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HideProduct - Ürünü sahibinden bağımsız olarak listelerden gizle
+// (products:moderate yetkisi gerekir)
+func (h *ProductHandler) HideProduct(c *gin.Context) {
+	h.setHidden(c, true)
+}
+
+// UnhideProduct - Gizlenmiş ürünü tekrar listele
+func (h *ProductHandler) UnhideProduct(c *gin.Context) {
+	h.setHidden(c, false)
+}
+
+func (h *ProductHandler) setHidden(c *gin.Context, hidden bool) {
+	var product models.Product
+	if err := database.DB.WithContext(c.Request.Context()).First(&product, c.Param("id")).Error; err != nil {
+		response.Error(c, http.StatusNotFound, "Ürün bulunamadı")
+		return
+	}
+
+	var hiddenAt *time.Time
+	if hidden {
+		now := time.Now()
+		hiddenAt = &now
+	}
+	if err := database.DB.WithContext(c.Request.Context()).Model(&product).Update("hidden_at", hiddenAt).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürün güncellenemedi")
+		return
+	}
+
+	logger.FromGin(c).Info("product visibility changed", "product_id", product.ID, "hidden", hidden, "by", identity.FromContext(c).UserID)
+
+	message := "Ürün tekrar listelendi"
+	if hidden {
+		message = "Ürün gizlendi"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
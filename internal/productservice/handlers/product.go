@@ -1,42 +1,47 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
 	"enchanted-micro/internal/productservice/config"
 	"enchanted-micro/internal/productservice/database"
 	"enchanted-micro/internal/productservice/models"
+	"enchanted-micro/internal/productservice/sellers"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type ProductHandler struct {
-	config *config.Config
+	config  *config.Config
+	sellers *sellers.Client
 }
 
-func NewProductHandler(cfg *config.Config) *ProductHandler {
-	return &ProductHandler{config: cfg}
+func NewProductHandler(cfg *config.Config, sellerProfiles *sellers.Client) *ProductHandler {
+	return &ProductHandler{config: cfg, sellers: sellerProfiles}
 }
 
 // CreateProduct - Yeni ürün oluştur
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
 	var req models.CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -50,8 +55,8 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	}
 
 	// Veritabanına kaydet
-	if err := database.DB.Create(&product).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün oluşturulamadı"})
+	if err := database.DB.WithContext(c.Request.Context()).Create(&product).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürün oluşturulamadı")
 		return
 	}
 
@@ -78,7 +83,7 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 func (h *ProductHandler) UploadProductImage(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
@@ -86,15 +91,15 @@ func (h *ProductHandler) UploadProductImage(c *gin.Context) {
 	
 	// Ürünün kullanıcıya ait olup olmadığını kontrol et
 	var product models.Product
-	if err := database.DB.Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı veya size ait değil"})
+	if err := database.DB.WithContext(c.Request.Context()).Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
+		response.Error(c, http.StatusNotFound, "Ürün bulunamadı veya size ait değil")
 		return
 	}
 
 	// Dosya yükle
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Resim dosyası gerekli"})
+		response.Error(c, http.StatusBadRequest, "Resim dosyası gerekli")
 		return
 	}
 	defer file.Close()
@@ -103,14 +108,14 @@ func (h *ProductHandler) UploadProductImage(c *gin.Context) {
 	ext := strings.ToLower(filepath.Ext(header.Filename))
 	allowedExts := []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
 	if !contains(allowedExts, ext) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz dosya formatı. Sadece jpg, jpeg, png, gif, webp kabul edilir"})
+		response.Error(c, http.StatusBadRequest, "Geçersiz dosya formatı. Sadece jpg, jpeg, png, gif, webp kabul edilir")
 		return
 	}
 
 	// Upload klasörünü oluştur
 	uploadDir := h.config.UploadPath
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload klasörü oluşturulamadı"})
+		response.Error(c, http.StatusInternalServerError, "Upload klasörü oluşturulamadı")
 		return
 	}
 
@@ -121,23 +126,29 @@ func (h *ProductHandler) UploadProductImage(c *gin.Context) {
 	// Dosyayı kaydet
 	dst, err := os.Create(filePath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dosya kaydedilemedi"})
+		response.Error(c, http.StatusInternalServerError, "Dosya kaydedilemedi")
 		return
 	}
 	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dosya kopyalanamadı"})
+	written, err := io.Copy(dst, file)
+	if err != nil {
+		uploads.WithLabelValues("failure").Inc()
+		response.Error(c, http.StatusInternalServerError, "Dosya kopyalanamadı")
 		return
 	}
+	uploadedBytes.Add(float64(written))
 
 	// Ürünün image_url'ini güncelle
 	imageURL := fmt.Sprintf("/uploads/%s", fileName)
-	if err := database.DB.Model(&product).Update("image_url", imageURL).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Resim URL'i güncellenemedi"})
+	if err := database.DB.WithContext(c.Request.Context()).Model(&product).Update("image_url", imageURL).Error; err != nil {
+		uploads.WithLabelValues("failure").Inc()
+		response.Error(c, http.StatusInternalServerError, "Resim URL'i güncellenemedi")
 		return
 	}
 
+	uploads.WithLabelValues("success").Inc()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "Resim başarıyla yüklendi",
 		"image_url": imageURL,
@@ -149,6 +160,7 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	category := c.Query("category")
+	includeSeller := contains(strings.Split(c.Query("include"), ","), "seller")
 
 	if page < 1 {
 		page = 1
@@ -162,7 +174,8 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var products []models.Product
 	var total int64
 
-	query := database.DB.Model(&models.Product{})
+	// Moderatörün gizlediği ürünler listelenmez
+	query := database.DB.WithContext(c.Request.Context()).Model(&models.Product{}).Where("hidden_at IS NULL")
 	
 	if category != "" {
 		query = query.Where("category = ?", category)
@@ -170,13 +183,13 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 
 	// Toplam sayıyı al
 	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler sayılamadı"})
+		response.Error(c, http.StatusInternalServerError, "Ürünler sayılamadı")
 		return
 	}
 
 	// Ürünleri al
 	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler getirilemedi"})
+		response.Error(c, http.StatusInternalServerError, "Ürünler getirilemedi")
 		return
 	}
 
@@ -196,6 +209,10 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		})
 	}
 
+	if includeSeller {
+		h.attachSellers(c, productResponses)
+	}
+
 	response := models.GetProductsResponse{
 		Products: productResponses,
 		Total:    total,
@@ -206,11 +223,34 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// attachSellers - Ürünlere satıcı profillerini ekle. User service'e
+// ulaşılamazsa ürünler satıcısız döner.
+func (h *ProductHandler) attachSellers(c *gin.Context, products []models.ProductResponse) {
+	var ids []uint
+	for _, product := range products {
+		ids = append(ids, product.UserID)
+	}
+
+	profiles, err := h.sellers.Lookup(c.Request.Context(), ids)
+	switch {
+	case errors.Is(err, sellers.ErrUnavailable):
+		// Hata ilk başarısız denemede loglandı
+		logger.FromGin(c).Debug("seller profiles skipped during backoff")
+	case err != nil:
+		logger.FromGin(c).Warn("seller profiles unavailable", "error", err)
+	}
+	for i := range products {
+		if seller, ok := profiles[products[i].UserID]; ok {
+			products[i].Seller = &seller
+		}
+	}
+}
+
 // GetMyProducts - Kullanıcının kendi ürünlerini getir
 func (h *ProductHandler) GetMyProducts(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
@@ -230,14 +270,14 @@ func (h *ProductHandler) GetMyProducts(c *gin.Context) {
 	var total int64
 
 	// Kullanıcının ürünlerini say
-	if err := database.DB.Model(&models.Product{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler sayılamadı"})
+	if err := database.DB.WithContext(c.Request.Context()).Model(&models.Product{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürünler sayılamadı")
 		return
 	}
 
 	// Kullanıcının ürünlerini al
-	if err := database.DB.Where("user_id = ?", userID).Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler getirilemedi"})
+	if err := database.DB.WithContext(c.Request.Context()).Where("user_id = ?", userID).Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürünler getirilemedi")
 		return
 	}
 
@@ -252,6 +292,7 @@ func (h *ProductHandler) GetMyProducts(c *gin.Context) {
 			ImageURL:    product.ImageURL,
 			Category:    product.Category,
 			UserID:      product.UserID,
+			Hidden:      product.HiddenAt != nil,
 			CreatedAt:   product.CreatedAt,
 			UpdatedAt:   product.UpdatedAt,
 		})
@@ -271,7 +312,7 @@ func (h *ProductHandler) GetMyProducts(c *gin.Context) {
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
@@ -279,14 +320,14 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	
 	// Ürünü bul
 	var product models.Product
-	if err := database.DB.Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı veya size ait değil"})
+	if err := database.DB.WithContext(c.Request.Context()).Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
+		response.Error(c, http.StatusNotFound, "Ürün bulunamadı veya size ait değil")
 		return
 	}
 
 	var req models.UpdateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -305,13 +346,13 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		updates["category"] = req.Category
 	}
 
-	if err := database.DB.Model(&product).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün güncellenemedi"})
+	if err := database.DB.WithContext(c.Request.Context()).Model(&product).Updates(updates).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürün güncellenemedi")
 		return
 	}
 
 	// Güncellenmiş ürünü getir
-	database.DB.First(&product, productID)
+	database.DB.WithContext(c.Request.Context()).First(&product, productID)
 
 	response := models.ProductResponse{
 		ID:          product.ID,
@@ -335,16 +376,20 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
 	productID := c.Param("id")
 	
-	// Ürünü bul
+	// Ürünü bul; moderatörler herkesin ürününü silebilir
+	query := database.DB.WithContext(c.Request.Context()).Where("id = ?", productID)
+	if !identity.FromContext(c).HasPermission(identity.PermProductsModerate) {
+		query = query.Where("user_id = ?", userID)
+	}
 	var product models.Product
-	if err := database.DB.Where("id = ? AND user_id = ?", productID, userID).First(&product).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı veya size ait değil"})
+	if err := query.First(&product).Error; err != nil {
+		response.Error(c, http.StatusNotFound, "Ürün bulunamadı veya size ait değil")
 		return
 	}
 
@@ -352,16 +397,20 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	if product.ImageURL != "" {
 		imagePath := filepath.Join(h.config.UploadPath, filepath.Base(product.ImageURL))
 		if err := os.Remove(imagePath); err != nil {
-			log.Printf("Resim silinemedi: %v", err)
+			logger.FromGin(c).Warn("product image could not be removed", "product_id", product.ID, "path", imagePath, "error", err)
 		}
 	}
 
 	// Ürünü sil
-	if err := database.DB.Delete(&product).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün silinemedi"})
+	if err := database.DB.WithContext(c.Request.Context()).Delete(&product).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Ürün silinemedi")
 		return
 	}
 
+	if product.UserID != userID.(uint) {
+		logger.FromGin(c).Info("product deleted by moderator", "product_id", product.ID, "owner_id", product.UserID, "by", userID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Ürün başarıyla silindi"})
 }
 
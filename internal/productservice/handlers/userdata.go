@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/userdata"
+	"enchanted-micro/internal/productservice/database"
+	"enchanted-micro/internal/productservice/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportUserData - Kullanıcının gizlenmiş olanlar dahil tüm ürünleri ve
+// resimleri; user service veri dışa aktarımında kullanır
+func (h *ProductHandler) ExportUserData(ctx context.Context, userID uint) (*userdata.Export, error) {
+	var products []models.Product
+	if err := database.DB.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	export := &userdata.Export{Files: []string{}}
+	productResponses := []models.ProductResponse{}
+	for _, product := range products {
+		productResponses = append(productResponses, models.ProductResponse{
+			ID:          product.ID,
+			Title:       product.Title,
+			Description: product.Description,
+			Price:       product.Price,
+			ImageURL:    product.ImageURL,
+			Category:    product.Category,
+			UserID:      product.UserID,
+			Hidden:      product.HiddenAt != nil,
+			CreatedAt:   product.CreatedAt,
+			UpdatedAt:   product.UpdatedAt,
+		})
+		if product.ImageURL != "" {
+			export.Files = append(export.Files, product.ImageURL)
+		}
+	}
+
+	data, err := json.Marshal(gin.H{"products": productResponses})
+	if err != nil {
+		return nil, err
+	}
+	export.Data = data
+	return export, nil
+}
+
+// EraseUserData - Hesabı silinen kullanıcının ürünlerini (daha önce
+// silinmiş olanlar dahil) kalıcı olarak ve resimleriyle birlikte siler
+func (h *ProductHandler) EraseUserData(ctx context.Context, userID uint) error {
+	db := database.DB.WithContext(ctx).Unscoped()
+
+	var products []models.Product
+	if err := db.Where("user_id = ?", userID).Find(&products).Error; err != nil {
+		return err
+	}
+	for _, product := range products {
+		if product.ImageURL == "" {
+			continue
+		}
+		imagePath := filepath.Join(h.config.UploadPath, filepath.Base(product.ImageURL))
+		if err := os.Remove(imagePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	res := db.Where("user_id = ?", userID).Delete(&models.Product{})
+	if res.Error != nil {
+		return res.Error
+	}
+	logger.FromContext(ctx).Info("user products erased", "user_id", userID, "count", res.RowsAffected)
+	return nil
+}
@@ -1,60 +1,22 @@
 package middleware
 
 import (
-	"net/http"
-	"strings"
-
+	"enchanted-micro/internal/pkg/identity"
 	"enchanted-micro/internal/productservice/config"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header gerekli"})
-			c.Abort()
-			return
-		}
-
-		// "Bearer " prefix'ini kaldır
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token formatı"})
-			c.Abort()
-			return
-		}
-
-		// Token'ı parse et
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token"})
-			c.Abort()
-			return
-		}
-
-		// Claims'den user ID'yi al
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token claims"})
-			c.Abort()
-			return
-		}
-
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz user ID"})
-			c.Abort()
-			return
-		}
-
-		// User ID'yi context'e ekle
-		c.Set("user_id", uint(userID))
-		c.Next()
-	}
+// AuthMiddleware - Gateway'in imzaladığı kimlik header'larını ya da Bearer token'ı doğrular,
+// token imzaları user servisinin JWKS'inden alınan anahtarlarla kontrol edilir;
+// çıkış yapılmış token'ları reddeder (revocations nil ise kontrol yapılmaz).
+// X-API-Key header'ı ya da "ApiKey" şemasıyla gelen API anahtarları apiKeys
+// ile doğrulanır; apiKeys nil ise kabul edilmez.
+func AuthMiddleware(cfg *config.Config, keys identity.Keys, revocations identity.RevocationChecker, apiKeys identity.APIKeyVerifier) gin.HandlerFunc {
+	return identity.Middleware(identity.Options{
+		Keys:           keys,
+		IdentitySecret: cfg.IdentitySecret,
+		Revocations:    revocations,
+		APIKeys:        apiKeys,
+	})
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/productservice/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail - REQUIRE_VERIFIED_EMAIL açıksa token'ın email_verified
+// claim'i olmayan kullanıcıları reddeder. AuthMiddleware'den sonra kullanılır.
+func RequireVerifiedEmail(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.RequireVerifiedEmail {
+			c.Next()
+			return
+		}
+
+		id := identity.FromContext(c)
+		if id == nil || !id.EmailVerified {
+			response.Error(c, http.StatusForbidden, "İlan oluşturmak için e-posta adresinizi doğrulayın")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
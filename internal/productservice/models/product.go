@@ -14,6 +14,7 @@ type Product struct {
 	ImageURL    string         `json:"image_url"`
 	Category    string         `json:"category"`
 	UserID      uint           `json:"user_id" gorm:"not null"`
+	HiddenAt    *time.Time     `json:"hidden_at,omitempty" gorm:"index"` // Moderatör gizlediyse dolu, listelenmez
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -34,17 +35,30 @@ type UpdateProductRequest struct {
 }
 
 type ProductResponse struct {
-	ID          uint    `json:"id"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	ImageURL    string  `json:"image_url"`
-	Category    string  `json:"category"`
-	UserID      uint    `json:"user_id"`
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Price       float64   `json:"price"`
+	ImageURL    string    `json:"image_url"`
+	Category    string    `json:"category"`
+	UserID      uint      `json:"user_id"`
+	Hidden      bool      `json:"hidden,omitempty"`
+	Seller      *Seller   `json:"seller,omitempty"` // Sadece ?include=seller ile
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Seller - User service'teki herkese açık profilin ilanlarda gösterilen kısmı
+type Seller struct {
+	ID          uint              `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	AvatarURL   string            `json:"avatar_url"`
+	AvatarURLs  map[string]string `json:"avatar_urls,omitempty"` // Boyut adına göre (small, medium, large)
+	Location    string            `json:"location"`
+	JoinedAt    time.Time         `json:"joined_at"`
+}
+
 type GetProductsResponse struct {
 	Products []ProductResponse `json:"products"`
 	Total    int64             `json:"total"`
@@ -0,0 +1,171 @@
+// Package sellers looks up the public profiles of product owners in the user
+// service so listings can show who is selling. Profiles are cached, and
+// users without a public profile are cached too, so a busy listing page does
+// not cost a user service round trip per request. After a failed lookup the
+// user service is left alone for a few seconds, so an outage does not add
+// the client timeout to every listing request.
+package sellers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"enchanted-micro/internal/productservice/models"
+)
+
+// Path is the user service's batch profile endpoint
+const Path = "/users"
+
+// maxBatch is how many ids the user service answers per request
+const maxBatch = 100
+
+// failureBackoff is how long lookups skip the user service after it failed
+const failureBackoff = 10 * time.Second
+
+// ErrUnavailable is returned without a request while backing off after a
+// failed lookup
+var ErrUnavailable = errors.New("seller lookup: user service unavailable")
+
+// Client fetches profiles from the user service at baseURL
+type Client struct {
+	endpoint string
+	client   *http.Client
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cache     map[uint]entry
+	lastPrune time.Time
+	// Lookups until then only use the cache
+	backoffUntil time.Time
+}
+
+type entry struct {
+	seller  *models.Seller // nil for users without a public profile
+	expires time.Time
+}
+
+// NewClient returns a client caching profiles for ttl
+func NewClient(baseURL string, client *http.Client, ttl time.Duration) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(baseURL, "/") + Path,
+		client:   client,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[uint]entry),
+	}
+}
+
+// Lookup returns the sellers with the given ids. Ids without a public
+// profile, e.g. disabled accounts, are missing from the result. When the
+// user service cannot be reached the cached sellers are returned with the
+// error, and for failureBackoff with ErrUnavailable.
+func (c *Client) Lookup(ctx context.Context, ids []uint) (map[uint]models.Seller, error) {
+	now := c.now()
+	sellers := make(map[uint]models.Seller, len(ids))
+	var missing []uint
+	queued := make(map[uint]bool)
+
+	c.mu.Lock()
+	for _, id := range ids {
+		e, ok := c.cache[id]
+		switch {
+		case !ok || now.After(e.expires):
+			if !queued[id] {
+				queued[id] = true
+				missing = append(missing, id)
+			}
+		case e.seller != nil:
+			sellers[id] = *e.seller
+		}
+	}
+	backoff := now.Before(c.backoffUntil)
+	c.mu.Unlock()
+
+	if len(missing) > 0 && backoff {
+		return sellers, ErrUnavailable
+	}
+
+	for len(missing) > 0 {
+		batch := missing[:min(len(missing), maxBatch)]
+		missing = missing[len(batch):]
+
+		fetched, err := c.fetch(ctx, batch)
+		if err != nil {
+			// A caller giving up is no sign of an outage
+			if ctx.Err() == nil {
+				c.mu.Lock()
+				c.backoffUntil = now.Add(failureBackoff)
+				c.mu.Unlock()
+			}
+			return sellers, err
+		}
+
+		c.mu.Lock()
+		c.prune(now)
+		for _, id := range batch {
+			e := entry{expires: now.Add(c.ttl)}
+			if seller, ok := fetched[id]; ok {
+				e.seller = &seller
+				sellers[id] = seller
+			}
+			c.cache[id] = e
+		}
+		c.mu.Unlock()
+	}
+	return sellers, nil
+}
+
+func (c *Client) fetch(ctx context.Context, ids []uint) (map[uint]models.Seller, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?ids="+url.QueryEscape(strings.Join(parts, ",")), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("seller lookup: unexpected status %d", resp.StatusCode)
+	}
+	var body struct {
+		Users []models.Seller `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	sellers := make(map[uint]models.Seller, len(body.Users))
+	for _, seller := range body.Users {
+		sellers[seller.ID] = seller
+	}
+	return sellers, nil
+}
+
+// prune drops expired entries at most once per ttl; c.mu must be held
+func (c *Client) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < c.ttl {
+		return
+	}
+	c.lastPrune = now
+	for id, e := range c.cache {
+		if now.After(e.expires) {
+			delete(c.cache, id)
+		}
+	}
+}
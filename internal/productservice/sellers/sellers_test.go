@@ -0,0 +1,101 @@
+package sellers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/productservice/models"
+)
+
+// userService answers profile lookups for user 1 only, or fails while down
+type userService struct {
+	srv      *httptest.Server
+	requests atomic.Int32
+	down     atomic.Bool
+}
+
+func newUserService(t *testing.T) *userService {
+	t.Helper()
+	u := &userService{}
+	u.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.requests.Add(1)
+		if u.down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"users": []models.Seller{{ID: 1, Username: "ayse"}}})
+	}))
+	t.Cleanup(u.srv.Close)
+	return u
+}
+
+func TestLookupCaches(t *testing.T) {
+	ctx := context.Background()
+	u := newUserService(t)
+	c := NewClient(u.srv.URL, u.srv.Client(), time.Minute)
+
+	for range 2 {
+		sellers, err := c.Lookup(ctx, []uint{1, 2, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sellers) != 1 || sellers[1].Username != "ayse" {
+			t.Fatalf("sellers %+v", sellers)
+		}
+	}
+	// User 2 has no public profile; that is cached as well
+	if n := u.requests.Load(); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+}
+
+func TestLookupBacksOffAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	u := newUserService(t)
+	c := NewClient(u.srv.URL, u.srv.Client(), time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Lookup(ctx, []uint{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	u.down.Store(true)
+	sellers, err := c.Lookup(ctx, []uint{1, 2})
+	if err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("first failure: %v", err)
+	}
+	if len(sellers) != 1 {
+		t.Fatalf("cached sellers not returned: %+v", sellers)
+	}
+
+	// Within the backoff the user service is not asked again
+	for range 3 {
+		if _, err := c.Lookup(ctx, []uint{2}); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("during backoff: %v, want ErrUnavailable", err)
+		}
+	}
+	// Cached ids need no request and report no error
+	if _, err := c.Lookup(ctx, []uint{1}); err != nil {
+		t.Fatalf("cached lookup during backoff: %v", err)
+	}
+	if n := u.requests.Load(); n != 2 {
+		t.Fatalf("%d requests, want 2", n)
+	}
+
+	// Afterwards lookups try again
+	u.down.Store(false)
+	now = now.Add(failureBackoff)
+	if _, err := c.Lookup(ctx, []uint{2}); err != nil {
+		t.Fatal(err)
+	}
+	if n := u.requests.Load(); n != 3 {
+		t.Fatalf("%d requests, want 3", n)
+	}
+}
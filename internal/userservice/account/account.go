@@ -0,0 +1,305 @@
+// Package account exports a user's data and deletes accounts once their
+// deletion grace period has passed. Both reach into the product service,
+// which holds the user's listings and images.
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"enchanted-micro/internal/pkg/userdata"
+	"enchanted-micro/internal/userservice/avatar"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrProductsUnavailable is returned when the product service cannot be
+// reached because INTERNAL_API_SECRET is not set
+var ErrProductsUnavailable = errors.New("product service not configured")
+
+// EventAccountDeleted is written to the audit log when an account is deleted
+const EventAccountDeleted = "account_deleted"
+
+// deleteTimeout bounds the deletion of a single account, product service
+// call included
+const deleteTimeout = time.Minute
+
+// Manager exports and deletes accounts
+type Manager struct {
+	db *gorm.DB
+	// products is nil without INTERNAL_API_SECRET
+	products *userdata.Client
+	avatars  *avatar.Store
+	now      func() time.Time
+}
+
+// NewManager builds a manager from the service config
+func NewManager(db *gorm.DB, cfg *config.Config, avatars *avatar.Store) *Manager {
+	m := &Manager{db: db, avatars: avatars, now: time.Now}
+	if cfg.InternalAPISecret != "" {
+		m.products = userdata.NewClient(cfg.ProductServiceURL, cfg.InternalAPISecret, &http.Client{Timeout: time.Minute})
+	} else {
+		slog.Warn("INTERNAL_API_SECRET not set, data exports and account deletions are unavailable")
+	}
+	return m
+}
+
+// Archive is a user's data export
+type Archive struct {
+	account  accountData
+	products *userdata.Export
+	client   *userdata.Client
+	avatars  *avatar.Store
+}
+
+// accountData is account.json in the archive
+type accountData struct {
+	User             models.User             `json:"user"`
+	LinkedIdentities []models.LinkedIdentity `json:"linked_identities"`
+	Sessions         []models.Session        `json:"sessions"`
+	APIKeys          []models.APIKey         `json:"api_keys"`
+	AuditLogs        []models.AuditLog       `json:"audit_logs"`
+	ExportedAt       time.Time               `json:"exported_at"`
+}
+
+// Export collects the user's data from this service and the product
+// service. Files are downloaded only when the archive is written.
+func (m *Manager) Export(ctx context.Context, userID uint) (*Archive, error) {
+	if m.products == nil {
+		return nil, ErrProductsUnavailable
+	}
+
+	db := m.db.WithContext(ctx)
+	a := &Archive{client: m.products, avatars: m.avatars, account: accountData{ExportedAt: m.now()}}
+	if err := db.First(&a.account.User, userID).Error; err != nil {
+		return nil, err
+	}
+	for _, records := range []any{&a.account.LinkedIdentities, &a.account.Sessions, &a.account.APIKeys, &a.account.AuditLogs} {
+		if err := db.Where("user_id = ?", userID).Order("created_at").Find(records).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	products, err := m.products.Export(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("product export: %w", err)
+	}
+	a.products = products
+	return a, nil
+}
+
+// Write writes the archive to w as a ZIP file: account.json, the avatar,
+// products.json and the product images under products/. Images that cannot
+// be read are left out.
+func (a *Archive) Write(ctx context.Context, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	account, err := json.MarshalIndent(a.account, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(zw, "account.json", account); err != nil {
+		return err
+	}
+	if url, ok := a.account.User.AvatarURLs[avatar.DefaultSize]; ok {
+		if err := a.copyAvatar(zw, url); err != nil {
+			slog.Warn("export avatar skipped", "file", url, "error", err)
+		}
+	}
+	var products bytes.Buffer
+	if err := json.Indent(&products, a.products.Data, "", "  "); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "products.json", products.Bytes()); err != nil {
+		return err
+	}
+
+	for _, file := range a.products.Files {
+		if err := a.copyFile(ctx, zw, file); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Warn("export file skipped", "file", file, "error", err)
+		}
+	}
+	return zw.Close()
+}
+
+func (a *Archive) copyFile(ctx context.Context, zw *zip.Writer, file string) error {
+	body, err := a.client.Open(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Images are compressed already
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: "products/" + path.Base(file), Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, body)
+	return err
+}
+
+func (a *Archive) copyAvatar(zw *zip.Writer, url string) error {
+	f, err := a.avatars.Open(url)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: "avatar.jpg", Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(data)
+	return err
+}
+
+// Run deletes the accounts whose grace period has passed every interval
+// until ctx is done. Failed deletions are retried on the next run.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.deleteDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) deleteDue(ctx context.Context) {
+	var due []uint
+	err := m.db.WithContext(ctx).Model(&models.User{}).Unscoped().
+		Where("deletion_scheduled_at <= ?", m.now()).
+		Pluck("id", &due).Error
+	if err != nil {
+		slog.Warn("accounts due for deletion could not be listed", "error", err)
+		return
+	}
+
+	for _, userID := range due {
+		deleteCtx, cancel := context.WithTimeout(ctx, deleteTimeout)
+		err := m.Delete(deleteCtx, userID)
+		cancel()
+		if err != nil {
+			slog.Error("account deletion failed", "user_id", userID, "error", err)
+			continue
+		}
+		slog.Info("account deleted", "user_id", userID)
+	}
+}
+
+// Delete erases an account whose grace period has passed: its listings and
+// images in the product service, then everything this service stores about
+// it, avatar included. Audit log entries are kept without the username and
+// IP.
+//
+// The account is first claimed by setting deleting_at, which stops restores
+// and other instances; the product service erases without a transaction
+// open, and the rest is deleted in a second short transaction. A claim left
+// behind by a crashed instance is taken over after deleteTimeout.
+func (m *Manager) Delete(ctx context.Context, userID uint) error {
+	if m.products == nil {
+		return ErrProductsUnavailable
+	}
+
+	db := m.db.WithContext(ctx)
+	// Databases keep microseconds; the claim is compared when finishing
+	claimedAt := m.now().Truncate(time.Microsecond)
+	res := db.Model(&models.User{}).Unscoped().
+		Where("id = ? AND deletion_scheduled_at <= ?", userID, claimedAt).
+		Where("deleting_at IS NULL OR deleting_at <= ?", claimedAt.Add(-deleteTimeout)).
+		Update("deleting_at", claimedAt)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Restored, deleted or being deleted by another instance
+		return nil
+	}
+
+	if err := m.products.Erase(ctx, userID); err != nil {
+		// Release the claim so the account can still be restored until the
+		// next attempt
+		release := m.db.WithContext(context.WithoutCancel(ctx)).Model(&models.User{}).Unscoped().
+			Where("id = ? AND deleting_at = ?", userID, claimedAt).
+			Update("deleting_at", nil)
+		if release.Error != nil {
+			slog.Warn("account deletion claim could not be released", "user_id", userID, "error", release.Error)
+		}
+		return fmt.Errorf("product erase: %w", err)
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleting_at = ?", claimedAt).
+			First(&user, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Taken over by another instance after the claim expired
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, model := range []any{
+			&models.RefreshToken{}, &models.RevokedToken{}, &models.Session{},
+			&models.PasswordResetToken{}, &models.EmailVerificationToken{},
+			&models.TwoFactor{}, &models.RecoveryCode{}, &models.MFAChallenge{},
+			&models.APIKey{}, &models.LinkedIdentity{}, &models.OAuthLoginCode{},
+			&models.OAuthLinkCode{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("link_user_id = ?", userID).Delete(&models.OAuthState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AuditLog{}).Where("user_id = ?", userID).
+			Updates(map[string]any{"subject": "", "ip": ""}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AuditLog{Event: EventAccountDeleted, UserID: &userID}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&user).Error
+	})
+	if err != nil || user.ID == 0 {
+		return err
+	}
+
+	// The account is gone; leftover files are only logged
+	if err := m.avatars.Remove(user.AvatarURLs); err != nil {
+		slog.Warn("avatar files of deleted account could not be removed", "user_id", userID, "error", err)
+	}
+	return nil
+}
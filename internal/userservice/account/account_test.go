@@ -0,0 +1,144 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/pkg/userdata"
+	"enchanted-micro/internal/userservice/avatar"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// testManager returns a manager on an in-memory database whose product
+// service runs erase for every erase request
+func testManager(t *testing.T, erase func(db *gorm.DB) int) (*Manager, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection: a transaction left open blocks every other query
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(
+		&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.Session{},
+		&models.PasswordResetToken{}, &models.EmailVerificationToken{},
+		&models.TwoFactor{}, &models.RecoveryCode{}, &models.MFAChallenge{},
+		&models.APIKey{}, &models.LinkedIdentity{}, &models.OAuthLoginCode{},
+		&models.OAuthLinkCode{}, &models.OAuthState{}, &models.AuditLog{},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	products := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(erase(db))
+	}))
+	t.Cleanup(products.Close)
+
+	m := &Manager{
+		db:       db,
+		products: userdata.NewClient(products.URL, "secret", products.Client()),
+		avatars:  avatar.NewStore(t.TempDir()),
+		now:      time.Now,
+	}
+	return m, db
+}
+
+func scheduledUser(t *testing.T, db *gorm.DB) models.User {
+	t.Helper()
+	due := time.Now().Add(-time.Hour)
+	user := models.User{Username: "ayse", Email: "ayse@example.com", DeletionScheduledAt: &due}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestDeleteErasesOutsideTransaction(t *testing.T) {
+	var claimed int64
+	m, db := testManager(t, func(db *gorm.DB) int {
+		// Blocks on the single connection if a transaction is open
+		if err := db.Model(&models.User{}).Where("deleting_at IS NOT NULL").Count(&claimed).Error; err != nil {
+			return http.StatusInternalServerError
+		}
+		return http.StatusNoContent
+	})
+	user := scheduledUser(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if claimed != 1 {
+		t.Fatalf("account not claimed during erase")
+	}
+
+	var left int64
+	if err := db.Unscoped().Model(&models.User{}).Count(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Fatal("account not deleted")
+	}
+	var deleted int64
+	db.Model(&models.AuditLog{}).Where("event = ? AND user_id = ?", EventAccountDeleted, user.ID).Count(&deleted)
+	if deleted != 1 {
+		t.Fatal("deletion not audited")
+	}
+}
+
+func TestDeleteReleasesClaimWhenEraseFails(t *testing.T) {
+	m, db := testManager(t, func(*gorm.DB) int { return http.StatusBadGateway })
+	user := scheduledUser(t, db)
+
+	if err := m.Delete(context.Background(), user.ID); err == nil {
+		t.Fatal("expected erase error")
+	}
+	var got models.User
+	if err := db.First(&got, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.DeletingAt != nil {
+		t.Fatal("claim kept after failed erase")
+	}
+}
+
+func TestDeleteSkipsClaimedAccount(t *testing.T) {
+	erased := 0
+	m, db := testManager(t, func(*gorm.DB) int {
+		erased++
+		return http.StatusNoContent
+	})
+	user := scheduledUser(t, db)
+	now := time.Now()
+	if err := db.Model(&user).Update("deleting_at", now).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance is erasing
+	if err := m.Delete(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if erased != 0 {
+		t.Fatal("claimed account erased twice")
+	}
+
+	// Its claim expired, so it is taken over
+	m.now = func() time.Time { return now.Add(deleteTimeout + time.Second) }
+	if err := m.Delete(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if erased != 1 {
+		t.Fatal("expired claim not taken over")
+	}
+}
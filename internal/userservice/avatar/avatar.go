@@ -0,0 +1,175 @@
+// Package avatar turns uploaded pictures into profile avatars. Uploads are
+// recognized by their content rather than their file name, cropped to a
+// centered square and stored as JPEG files in several sizes under a
+// directory the service serves at URLPrefix.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// URLPrefix is where avatar files are served
+const URLPrefix = "/avatars"
+
+// Sizes are the edge lengths, in pixels, each avatar is stored in
+var Sizes = map[string]int{
+	"small":  64,
+	"medium": 128,
+	"large":  256,
+}
+
+// DefaultSize is the size shown where a single avatar URL is expected
+const DefaultSize = "large"
+
+const (
+	// minEdge is the shortest edge a picture may have
+	minEdge = 64
+	// maxPixels bounds the decoded size, so a small file cannot expand
+	// into gigabytes of pixels
+	maxPixels = 25_000_000
+	quality   = 85
+)
+
+var (
+	// ErrUnsupportedFormat is returned for files that are not JPEG, PNG,
+	// GIF or WebP pictures
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrDimensions is returned for pictures too small or too large
+	ErrDimensions = errors.New("image dimensions out of range")
+)
+
+// formats are the accepted content types as sniffed by http.DetectContentType
+var formats = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Decode checks the content type and dimensions of an uploaded picture
+// before decoding its pixels
+func Decode(data []byte) (image.Image, error) {
+	if !formats[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if min(cfg.Width, cfg.Height) < minEdge || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	return img, nil
+}
+
+// Store writes avatar files to a directory
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store writing to dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save crops img to a square and writes it in every size. It returns the
+// URL of each size.
+func (s *Store) Save(userID uint, img image.Image) (map[string]string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	square := centerSquare(img.Bounds())
+	id := uuid.New().String()
+	urls := make(map[string]string, len(Sizes))
+	for name, edge := range Sizes {
+		fileName := fmt.Sprintf("%d_%s_%s.jpg", userID, id, name)
+		if err := s.write(fileName, img, square, edge); err != nil {
+			s.Remove(urls)
+			return nil, err
+		}
+		urls[name] = URLPrefix + "/" + fileName
+	}
+	return urls, nil
+}
+
+func (s *Store) write(fileName string, img image.Image, square image.Rectangle, edge int) error {
+	// Transparent areas become white, JPEG has no alpha channel
+	dst := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, square, draw.Over, nil)
+
+	f, err := os.Create(filepath.Join(s.dir, fileName))
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, dst, &jpeg.Options{Quality: quality}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open opens the file behind an avatar URL; the caller closes it
+func (s *Store) Open(url string) (*os.File, error) {
+	path, ok := s.path(url)
+	if !ok {
+		return nil, fmt.Errorf("avatar %q: not in the store", url)
+	}
+	return os.Open(path)
+}
+
+// Remove deletes the files behind urls. URLs not pointing into the store
+// are ignored.
+func (s *Store) Remove(urls map[string]string) error {
+	var errs []error
+	for _, u := range urls {
+		path, ok := s.path(u)
+		if !ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// path maps a URL from Save back to its file
+func (s *Store) path(url string) (string, bool) {
+	fileName, ok := strings.CutPrefix(url, URLPrefix+"/")
+	if !ok || !strings.HasSuffix(fileName, ".jpg") || fileName != filepath.Base(fileName) {
+		return "", false
+	}
+	return filepath.Join(s.dir, fileName), true
+}
+
+// centerSquare is the largest square in the middle of r
+func centerSquare(r image.Rectangle) image.Rectangle {
+	edge := min(r.Dx(), r.Dy())
+	x := r.Min.X + (r.Dx()-edge)/2
+	y := r.Min.Y + (r.Dy()-edge)/2
+	return image.Rect(x, y, x+edge, y+edge)
+}
@@ -1,37 +1,149 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	JWTSecret  string
-	Port       string
+	DBHost         string
+	DBPort         string
+	DBUser         string
+	DBPassword     string
+	DBName         string
+	IdentitySecret string
+	// Access token'lar RS256/EdDSA ile imzalanır, anahtarlar periyodik değiştirilir
+	JWTAlgorithm        string
+	KeyRotationInterval time.Duration
+	// Veritabanındaki gizli verileri (imza anahtarları) şifreler
+	DataEncryptionKey string
+	// Access token'lar kısa ömürlü, refresh token'lar ile yenilenir
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Şifre sıfırlama token'larının ömrü ve e-postadaki linkin adresi
+	PasswordResetTTL time.Duration
+	AppURL           string
+	// E-posta doğrulama token'larının ömrü ve yeniden gönderim aralığı
+	EmailVerificationTTL    time.Duration
+	EmailVerificationResend time.Duration
+	// E-posta gönderimi: smtp, file ya da log
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// Başarısız girişler: hesap başına artan bekleme, eşik aşılınca geçici kilit
+	LoginMaxAttempts     int
+	LoginIPMaxAttempts   int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+	LoginDelayBase       time.Duration
+	LoginDelayMax        time.Duration
+	// İki adımlı doğrulama: authenticator uygulamasında görünen ad, giriş
+	// sırasında kodun girilebileceği süre ve deneme sayısı
+	TOTPIssuer           string
+	MFAChallengeTTL      time.Duration
+	MFAChallengeAttempts int
+	// OpenID Connect ile giriş: OIDC_PROVIDERS'ta listelenen sağlayıcılar ve
+	// /oauth endpoint'lerinin dışarıdan erişilen adresi (callback URL'leri
+	// bundan türetilir)
+	OIDCProviders []OIDCProvider
+	OAuthBaseURL  string
+	// API anahtarlarının en uzun geçerlilik süresi; süre verilmezse bu kullanılır
+	APIKeyMaxTTL time.Duration
+	// Hesap silme isteğinden sonra hesabın geri alınabileceği süre; süre
+	// dolunca hesap ve ürün servisindeki verileri silinir
+	AccountDeletionGrace time.Duration
+	ProductServiceURL    string
+	// Yüklenen avatarların kaydedildiği klasör ve en büyük dosya boyutu
+	AvatarUploadPath string
+	AvatarMaxBytes   int
+	// Başlangıçta admin rolü verilecek kullanıcı adları
+	AdminUsers []string
+	// Servisler arası /internal endpoint'leri için ortak secret
+	InternalAPISecret      string
+	RevocationSyncInterval time.Duration
+	// X-Forwarded-For yalnızca bu adreslerden (gateway) gelirse dikkate
+	// alınır; boşsa istemci IP'si bağlantının adresidir
+	TrustedProxies []string
+	LogLevel       string
+	LogFormat      string
+	TraceExporter  string
+	Port           string
+}
+
+// OIDCProvider - OpenID Connect sağlayıcısı. Uç noktalar Issuer'ın discovery
+// dokümanından okunur.
+type OIDCProvider struct {
+	Name         string // URL'lerde kullanılır: /oauth/<name>/authorize
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig() *Config {
 	// config.env dosyasını yükle
 	err := godotenv.Load("config.env")
 	if err != nil {
-		log.Println("config.env dosyası bulunamadı, sistem değişkenlerini kullanıyor")
+		slog.Info("config.env not found, using environment variables")
 	}
 
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:     getEnv("DB_NAME", "octopususerdb"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-super-secret-jwt-key-here"),
-		Port:       getEnv("PORT", "8080"),
+		DBHost:                  getEnv("DB_HOST", "localhost"),
+		DBPort:                  getEnv("DB_PORT", "5432"),
+		DBUser:                  getEnv("DB_USER", "postgres"),
+		DBPassword:              getEnv("DB_PASSWORD", "postgres"),
+		DBName:                  getEnv("DB_NAME", "octopususerdb"),
+		IdentitySecret:          getEnv("IDENTITY_SECRET", ""),
+		JWTAlgorithm:            getEnv("JWT_ALGORITHM", "RS256"),
+		KeyRotationInterval:     getDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		DataEncryptionKey:       getEnv("DATA_ENCRYPTION_KEY", ""),
+		AccessTokenTTL:          getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:         getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		PasswordResetTTL:        getDuration("PASSWORD_RESET_TTL", time.Hour),
+		AppURL:                  getEnv("APP_URL", "http://localhost:3000"),
+		EmailVerificationTTL:    getDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		EmailVerificationResend: getDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+		MailDriver:              getEnv("MAIL_DRIVER", "log"),
+		MailFrom:                getEnv("MAIL_FROM", "Enchanted <no-reply@enchanted.local>"),
+		MailDir:                 getEnv("MAIL_DIR", "./mail"),
+		SMTPHost:                getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:                getEnv("SMTP_PORT", "587"),
+		SMTPUsername:            getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:            getEnv("SMTP_PASSWORD", ""),
+		LoginMaxAttempts:        getInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:      getInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginFailureWindow:      getDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutDuration:    getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginDelayBase:          getDuration("LOGIN_DELAY_BASE", time.Second),
+		LoginDelayMax:           getDuration("LOGIN_DELAY_MAX", 30*time.Second),
+		TOTPIssuer:              getEnv("TOTP_ISSUER", "Enchanted"),
+		MFAChallengeTTL:         getDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MFAChallengeAttempts:    getInt("MFA_CHALLENGE_ATTEMPTS", 5),
+		OIDCProviders:           loadOIDCProviders(),
+		OAuthBaseURL:            getEnv("OAUTH_BASE_URL", "http://localhost:8090/user/oauth"),
+		APIKeyMaxTTL:            getDuration("API_KEY_MAX_TTL", 365*24*time.Hour),
+		AccountDeletionGrace:    getDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		ProductServiceURL:       getEnv("PRODUCT_SERVICE_URL", "http://localhost:8081"),
+		AvatarUploadPath:        getEnv("AVATAR_UPLOAD_PATH", "./uploads/avatars"),
+		AvatarMaxBytes:          getInt("AVATAR_MAX_BYTES", 5<<20),
+		AdminUsers:              getList("ADMIN_USERS"),
+		InternalAPISecret:       getEnv("INTERNAL_API_SECRET", ""),
+		RevocationSyncInterval:  getDuration("REVOCATION_SYNC_INTERVAL", 5*time.Second),
+		TrustedProxies:          getList("TRUSTED_PROXIES"),
+		Port:                    getEnv("PORT", "8080"),
+		LogLevel:                getEnv("LOG_LEVEL", "info"),
+		LogFormat:               getEnv("LOG_FORMAT", "json"),
+		TraceExporter:           getEnv("TRACE_EXPORTER", "none"),
 	}
 }
 
@@ -41,3 +153,67 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using default", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
+
+func getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// loadOIDCProviders - OIDC_PROVIDERS=google,mock için OIDC_GOOGLE_ISSUER,
+// OIDC_GOOGLE_CLIENT_ID, OIDC_GOOGLE_CLIENT_SECRET, OIDC_GOOGLE_SCOPES ve
+// OIDC_GOOGLE_DISPLAY_NAME okunur; issuer ya da client ID'si olmayanlar atlanır
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getList("OIDC_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getList(prefix + "SCOPES"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			slog.Warn("oidc provider skipped, issuer or client id missing", "provider", name)
+			continue
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
@@ -2,8 +2,13 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/metrics"
+	"enchanted-micro/internal/pkg/tracing"
 	"enchanted-micro/internal/userservice/config"
 	"enchanted-micro/internal/userservice/models"
 
@@ -18,20 +23,67 @@ func ConnectDB(cfg *config.Config) {
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.NewGorm()})
 	if err != nil {
-		log.Fatal("Veritabanına bağlanılamadı:", err)
+		logger.Fatal("database connection failed", "error", err)
 	}
 
-	log.Println("PostgreSQL veritabanına başarıyla bağlanıldı!")
+	slog.Info("database connected", "host", cfg.DBHost, "database", cfg.DBName)
+
+	if err := metrics.InstrumentGorm(DB, cfg.DBName); err != nil {
+		slog.Warn("database metrics disabled", "error", err)
+	}
+	if err := tracing.InstrumentGorm(DB); err != nil {
+		slog.Warn("database tracing disabled", "error", err)
+	}
 
 	// Auto migrate
-	err = DB.AutoMigrate(&models.User{})
+	err = DB.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.Session{}, &models.SigningKey{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.LoginFailure{}, &models.AuditLog{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.APIKey{}, &models.LinkedIdentity{}, &models.OAuthState{}, &models.OAuthLoginCode{}, &models.OAuthLinkCode{})
 	if err != nil {
-		log.Fatal("Migration hatası:", err)
+		logger.Fatal("database migration failed", "error", err)
 	}
 
-	log.Println("Veritabanı tabloları oluşturuldu!")
+	slog.Info("database migrated")
+
+	// ADMIN_USERS ile verilen kullanıcılar yönetici olur
+	if err := grantAdmins(DB, cfg.AdminUsers); err != nil {
+		logger.Fatal("admin bootstrap failed", "error", err)
+	}
+}
+
+// grantAdmins - İlk yöneticileri oluşturmak için verilen kullanıcı adlarına admin rolü ekler.
+// Kullanıcı adını herkes kaydedebildiğinden yalnızca e-postası doğrulanmış,
+// etkin hesaplar yönetici olur; diğerleri için uyarı yazılır.
+func grantAdmins(db *gorm.DB, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := db.Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		i := slices.IndexFunc(users, func(u models.User) bool { return u.Username == username })
+		if i < 0 {
+			slog.Warn("admin user not found, role not granted", "username", username)
+			continue
+		}
+		user := users[i]
+		if !user.EmailVerified() || user.Disabled() || user.DeletionScheduled() {
+			slog.Warn("admin user has no verified email or is inactive, role not granted", "user_id", user.ID, "username", username)
+			continue
+		}
+		if slices.Contains(user.Roles, identity.RoleAdmin) {
+			continue
+		}
+		user.Roles = append(user.Roles, identity.RoleAdmin)
+		if err := db.Model(&user).Select("roles").Updates(&user).Error; err != nil {
+			return err
+		}
+		slog.Info("admin role granted", "user_id", user.ID, "username", user.Username)
+	}
+	return nil
 }
 
 func GetDB() *gorm.DB {
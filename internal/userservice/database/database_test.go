@@ -0,0 +1,63 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGrantAdmins(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	users := []models.User{
+		{Username: "verified", Email: "verified@example.com", EmailVerifiedAt: &now},
+		{Username: "unverified", Email: "unverified@example.com"},
+		{Username: "disabled", Email: "disabled@example.com", EmailVerifiedAt: &now, DisabledAt: &now},
+		{Username: "leaving", Email: "leaving@example.com", EmailVerifiedAt: &now, DeletionScheduledAt: &now},
+		{Username: "bystander", Email: "bystander@example.com", EmailVerifiedAt: &now},
+	}
+	for i := range users {
+		users[i].Roles = []string{identity.RoleUser}
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := []string{"verified", "unverified", "disabled", "leaving", "missing"}
+	// Running twice must not add the role again
+	for range 2 {
+		if err := grantAdmins(db, names); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string][]string{
+		"verified":   {identity.RoleUser, identity.RoleAdmin},
+		"unverified": {identity.RoleUser},
+		"disabled":   {identity.RoleUser},
+		"leaving":    {identity.RoleUser},
+		"bystander":  {identity.RoleUser},
+	}
+	var got []models.User
+	if err := db.Find(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range got {
+		if !slices.Equal(u.Roles, want[u.Username]) {
+			t.Errorf("%s: roles %v, want %v", u.Username, u.Roles, want[u.Username])
+		}
+	}
+}
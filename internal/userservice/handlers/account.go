@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/account"
+	"enchanted-micro/internal/userservice/mfa"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountHandler - Hesap silme ve kişisel verilerin dışa aktarımı
+type AccountHandler struct {
+	tokens      *tokens.Issuer
+	revocations *revocation.Cache
+	mfa         *mfa.Manager
+	accounts    *account.Manager
+}
+
+func NewAccountHandler(issuer *tokens.Issuer, revocations *revocation.Cache, twoFactor *mfa.Manager, accounts *account.Manager) *AccountHandler {
+	return &AccountHandler{tokens: issuer, revocations: revocations, mfa: twoFactor, accounts: accounts}
+}
+
+// DeleteAccount - Hesabı silinmek üzere işaretle. Tüm oturumlar kapanır;
+// bekleme süresi dolana kadar giriş yapıp RestoreAccount ile geri alınabilir.
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	var req models.DeleteAccountRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if userModel.DeletionScheduledAt != nil {
+		response.Error(c, http.StatusConflict, "Hesap zaten silinmek üzere işaretlenmiş")
+		return
+	}
+	// Sadece harici sağlayıcıyla giriş yapan hesapların şifresi yoktur
+	if userModel.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(userModel.Password), []byte(req.Password)); err != nil {
+			response.Error(c, http.StatusForbidden, "Şifre hatalı")
+			return
+		}
+	}
+	if userModel.TwoFactorEnabled {
+		err := h.mfa.Verify(c.Request.Context(), userModel.ID, req.Code)
+		switch {
+		case errors.Is(err, mfa.ErrInvalidCode):
+			response.Error(c, http.StatusForbidden, "Geçersiz doğrulama kodu")
+			return
+		case errors.Is(err, mfa.ErrNotEnrolled):
+			response.Error(c, http.StatusBadRequest, "İki adımlı doğrulama açık değil")
+			return
+		case err != nil:
+			response.Error(c, http.StatusInternalServerError, "Kod doğrulanamadı")
+			return
+		}
+	}
+
+	deleteAt, version, err := h.tokens.ScheduleDeletion(c.Request.Context(), userModel.ID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap silinemedi")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+
+	logger.FromGin(c).Info("account deletion scheduled", "user_id", userModel.ID, "delete_at", deleteAt)
+	c.JSON(http.StatusOK, gin.H{
+		"message":               "Hesabınız silinmek üzere işaretlendi; bu tarihe kadar giriş yapıp geri alabilirsiniz",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
+
+// RestoreAccount - Silinmek üzere işaretlenmiş hesabı geri al
+func (h *AccountHandler) RestoreAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	err := h.tokens.CancelDeletion(c.Request.Context(), userModel.ID)
+	if errors.Is(err, tokens.ErrNoDeletionScheduled) {
+		response.Error(c, http.StatusConflict, "Hesap silinmek üzere işaretlenmemiş")
+		return
+	}
+	if errors.Is(err, tokens.ErrDeletionInProgress) {
+		response.Error(c, http.StatusConflict, "Hesap siliniyor, geri alınamaz")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap geri alınamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("account deletion cancelled", "user_id", userModel.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Hesabınız geri alındı"})
+}
+
+// ExportAccount - Hesap bilgileri, ilanlar ve ilan resimleri ZIP olarak
+func (h *AccountHandler) ExportAccount(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	archive, err := h.accounts.Export(c.Request.Context(), userModel.ID)
+	if err != nil {
+		logger.FromGin(c).Error("data export failed", "user_id", userModel.ID, "error", err)
+		response.Error(c, http.StatusServiceUnavailable, "Verileriniz şu anda dışa aktarılamıyor, daha sonra tekrar deneyin")
+		return
+	}
+
+	// Başlıklar gönderildikten sonraki hatalar sadece loglanabilir
+	fileName := fmt.Sprintf("enchanted-%s-%s.zip", userModel.Username, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	c.Status(http.StatusOK)
+	if err := archive.Write(c.Request.Context(), c.Writer); err != nil {
+		logger.FromGin(c).Error("data export could not be written", "user_id", userModel.ID, "error", err)
+		return
+	}
+
+	logger.FromGin(c).Info("data exported", "user_id", userModel.ID)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/lockout"
+	"enchanted-micro/internal/userservice/mfa"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler - Kullanıcı yönetimi endpoint'leri (RequirePermission ile korunur)
+type AdminHandler struct {
+	tokens      *tokens.Issuer
+	revocations *revocation.Cache
+	lockout     *lockout.Guard
+	mfa         *mfa.Manager
+}
+
+func NewAdminHandler(issuer *tokens.Issuer, revocations *revocation.Cache, guard *lockout.Guard, twoFactor *mfa.Manager) *AdminHandler {
+	return &AdminHandler{tokens: issuer, revocations: revocations, lockout: guard, mfa: twoFactor}
+}
+
+// ListUsers - Kullanıcıları listele; q kullanıcı adı ya da e-postada arar,
+// role ve disabled ile filtrelenebilir
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.WithContext(c.Request.Context()).Model(&models.User{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	if role := c.Query("role"); identity.ValidRole(role) {
+		// Roller JSON dizi olarak saklanır
+		query = query.Where("roles LIKE ?", `%"`+role+`"%`)
+	}
+	switch c.Query("disabled") {
+	case "true":
+		query = query.Where("disabled_at IS NOT NULL")
+	case "false":
+		query = query.Where("disabled_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Kullanıcılar sayılamadı")
+		return
+	}
+
+	var users []models.User
+	if err := query.Offset((page - 1) * limit).Limit(limit).Order("id").Find(&users).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Kullanıcılar getirilemedi")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ListUsersResponse{
+		Users: users,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// DisableUser - Hesabı devre dışı bırak; tüm oturumları kapanır
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	user, ok := h.target(c)
+	if !ok {
+		return
+	}
+	if user.ID == identity.FromContext(c).UserID {
+		response.Error(c, http.StatusBadRequest, "Kendi hesabınızı devre dışı bırakamazsınız")
+		return
+	}
+
+	version, err := h.tokens.Disable(c.Request.Context(), user.ID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap devre dışı bırakılamadı")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+
+	logger.FromGin(c).Info("user disabled", "user_id", user.ID, "by", identity.FromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Hesap devre dışı bırakıldı"})
+}
+
+// EnableUser - Devre dışı hesabı tekrar aç
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	user, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.WithContext(c.Request.Context()).Model(&user).Update("disabled_at", nil).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap açılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("user enabled", "user_id", user.ID, "by", identity.FromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Hesap tekrar açıldı"})
+}
+
+// UnlockUser - Başarısız girişler nedeniyle kilitlenen hesabı süresi
+// dolmadan aç
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	if err := h.lockout.Unlock(c.Request.Context(), user, identity.FromContext(c).UserID); err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap kilidi açılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("user unlocked", "user_id", user.ID, "by", identity.FromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Hesap kilidi açıldı"})
+}
+
+// ResetTwoFactor - Telefonunu ve kurtarma kodlarını kaybeden kullanıcının
+// iki adımlı doğrulamasını kapat
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	user, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Disable(c.Request.Context(), user.ID, identity.FromContext(c).UserID); err != nil {
+		response.Error(c, http.StatusInternalServerError, "İki adımlı doğrulama kapatılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("two-factor authentication reset", "user_id", user.ID, "by", identity.FromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "İki adımlı doğrulama kapatıldı"})
+}
+
+// ListAuditLogs - Güvenlik olaylarını yeniden eskiye listele; event ve
+// user_id ile filtrelenebilir
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.WithContext(c.Request.Context()).Model(&models.AuditLog{})
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Kayıtlar sayılamadı")
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := query.Offset((page - 1) * limit).Limit(limit).Order("id DESC").Find(&logs).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Kayıtlar getirilemedi")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ListAuditLogsResponse{
+		Logs:  logs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// UpdateRoles - Kullanıcının rollerini ve ek yetkilerini değiştir. Eski
+// rollerle verilmiş erişim token'ları geçersiz olur; yeni roller token
+// yenilenince gelir.
+func (h *AdminHandler) UpdateRoles(c *gin.Context) {
+	user, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, role := range req.Roles {
+		if !identity.ValidRole(role) {
+			response.Error(c, http.StatusBadRequest, "Geçersiz rol: "+role)
+			return
+		}
+	}
+	if user.ID == identity.FromContext(c).UserID && !slices.Contains(req.Roles, identity.RoleAdmin) {
+		response.Error(c, http.StatusBadRequest, "Kendi admin rolünüzü kaldıramazsınız")
+		return
+	}
+
+	version, err := h.tokens.UpdateRoles(c.Request.Context(), user.ID, req.Roles, req.Permissions)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Roller güncellenemedi")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+	user.Roles = req.Roles
+	user.Permissions = req.Permissions
+
+	logger.FromGin(c).Info("user roles updated", "user_id", user.ID, "roles", user.Roles, "by", identity.FromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Roller güncellendi",
+		"user":    user,
+	})
+}
+
+// target - URL'deki :id ile kullanıcıyı bulur, bulamazsa hata yazar
+func (h *AdminHandler) target(c *gin.Context) (models.User, bool) {
+	var user models.User
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Geçersiz kullanıcı ID")
+		return user, false
+	}
+	if err := database.DB.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
+		response.Error(c, http.StatusNotFound, "Kullanıcı bulunamadı")
+		return user, false
+	}
+	return user, true
+}
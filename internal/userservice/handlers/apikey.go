@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKey - Script'ler için kişisel API anahtarı oluştur; anahtar sadece
+// bu cevapta gösterilir
+func (h *UserHandler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !identity.ValidScope(scope) {
+			response.Error(c, http.StatusBadRequest, "Geçersiz yetki kapsamı: "+scope)
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	id := identity.FromContext(c)
+	created, err := h.tokens.CreateAPIKey(c.Request.Context(), id.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	switch {
+	case errors.Is(err, tokens.ErrInvalidAPIKeyExpiry):
+		response.Error(c, http.StatusBadRequest, "Geçerlilik süresi gelecekte ve en fazla "+h.config.APIKeyMaxTTL.String()+" sonra olmalı")
+		return
+	case errors.Is(err, tokens.ErrTooManyAPIKeys):
+		response.Error(c, http.StatusConflict, "API anahtarı sınırına ulaşıldı, kullanmadığınız anahtarları silin")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "API anahtarı oluşturulamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("api key created", "user_id", id.UserID, "api_key_id", created.ID)
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListAPIKeys - Kullanıcının aktif API anahtarları; anahtarların kendisi dönmez
+func (h *UserHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.tokens.ListAPIKeys(c.Request.Context(), identity.FromContext(c).UserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "API anahtarları alınamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey - API anahtarını iptal et
+func (h *UserHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Geçersiz API anahtarı ID")
+		return
+	}
+
+	id := identity.FromContext(c)
+	err = h.tokens.RevokeAPIKey(c.Request.Context(), id.UserID, uint(keyID))
+	if errors.Is(err, tokens.ErrAPIKeyNotFound) {
+		response.Error(c, http.StatusNotFound, "API anahtarı bulunamadı")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "API anahtarı iptal edilemedi")
+		return
+	}
+
+	logger.FromGin(c).Info("api key revoked", "user_id", id.UserID, "api_key_id", keyID)
+	c.JSON(http.StatusOK, gin.H{"message": "API anahtarı iptal edildi"})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/avatar"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// multipartOverhead - Dosya dışındaki form alanları ve sınırlar için pay
+const multipartOverhead = 64 << 10
+
+// AvatarHandler - Profil resmi yükleme ve kaldırma
+type AvatarHandler struct {
+	config  *config.Config
+	avatars *avatar.Store
+}
+
+func NewAvatarHandler(cfg *config.Config, avatars *avatar.Store) *AvatarHandler {
+	return &AvatarHandler{config: cfg, avatars: avatars}
+}
+
+// UploadAvatar - Resmi kare kırpıp farklı boyutlarda kaydet; önceki avatar
+// silinir
+func (h *AvatarHandler) UploadAvatar(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.config.AvatarMaxBytes)+multipartOverhead)
+	file, header, err := c.Request.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			avatarUploads.WithLabelValues("rejected").Inc()
+			response.Error(c, http.StatusRequestEntityTooLarge, "Resim dosyası çok büyük")
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Resim dosyası gerekli")
+		return
+	}
+	defer file.Close()
+	if header.Size > int64(h.config.AvatarMaxBytes) {
+		avatarUploads.WithLabelValues("rejected").Inc()
+		response.Error(c, http.StatusRequestEntityTooLarge, "Resim dosyası çok büyük")
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		avatarUploads.WithLabelValues("failure").Inc()
+		response.Error(c, http.StatusBadRequest, "Resim dosyası okunamadı")
+		return
+	}
+
+	// Dosya adı ve Content-Type'a değil içeriğe bakılır
+	img, err := avatar.Decode(data)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupportedFormat):
+		avatarUploads.WithLabelValues("rejected").Inc()
+		response.Error(c, http.StatusUnsupportedMediaType, "Geçersiz dosya formatı. Sadece jpg, png, gif, webp kabul edilir")
+		return
+	case errors.Is(err, avatar.ErrDimensions):
+		avatarUploads.WithLabelValues("rejected").Inc()
+		response.Error(c, http.StatusBadRequest, "Resim en az 64x64 piksel olmalı ve 25 megapikseli geçmemeli")
+		return
+	case err != nil:
+		avatarUploads.WithLabelValues("failure").Inc()
+		response.Error(c, http.StatusBadRequest, "Resim dosyası okunamadı")
+		return
+	}
+
+	urls, err := h.avatars.Save(userModel.ID, img)
+	if err != nil {
+		avatarUploads.WithLabelValues("failure").Inc()
+		logger.FromGin(c).Error("avatar could not be saved", "user_id", userModel.ID, "error", err)
+		response.Error(c, http.StatusInternalServerError, "Avatar kaydedilemedi")
+		return
+	}
+
+	previous, err := h.setAvatar(c, userModel.ID, urls)
+	if err != nil {
+		logger.FromGin(c).Error("avatar could not be stored", "user_id", userModel.ID, "error", err)
+		h.removeFiles(c, userModel.ID, urls)
+		avatarUploads.WithLabelValues("failure").Inc()
+		response.Error(c, http.StatusInternalServerError, "Avatar kaydedilemedi")
+		return
+	}
+	h.removeFiles(c, userModel.ID, previous)
+	avatarUploads.WithLabelValues("success").Inc()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Avatar başarıyla yüklendi",
+		"avatar_url":  urls[avatar.DefaultSize],
+		"avatar_urls": urls,
+	})
+}
+
+// DeleteAvatar - Avatarı kaldır
+func (h *AvatarHandler) DeleteAvatar(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	previous, err := h.setAvatar(c, userModel.ID, nil)
+	if err != nil {
+		logger.FromGin(c).Error("avatar could not be removed", "user_id", userModel.ID, "error", err)
+		response.Error(c, http.StatusInternalServerError, "Avatar kaldırılamadı")
+		return
+	}
+	h.removeFiles(c, userModel.ID, previous)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Avatar kaldırıldı"})
+}
+
+// setAvatar - Avatarı urls ile değiştir ve önceki dosyaların adreslerini
+// döndür. Satır kilitlenir; aynı anda yapılan iki yükleme birbirinin
+// dosyalarını silmez.
+func (h *AvatarHandler) setAvatar(c *gin.Context, userID uint, urls map[string]string) (map[string]string, error) {
+	var previous models.User
+	err := database.DB.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "avatar_urls").First(&previous, userID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{ID: userID}).
+			Select("avatar_url", "avatar_urls").
+			Updates(models.User{AvatarURL: urls[avatar.DefaultSize], AvatarURLs: urls}).Error
+	})
+	return previous.AvatarURLs, err
+}
+
+// removeFiles - Avatar dosyalarını sil; silinemeyen dosyalar sadece loglanır
+func (h *AvatarHandler) removeFiles(c *gin.Context, userID uint, urls map[string]string) {
+	if err := h.avatars.Remove(urls); err != nil {
+		logger.FromGin(c).Warn("avatar files could not be removed", "user_id", userID, "error", err)
+	}
+}
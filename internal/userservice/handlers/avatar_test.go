@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"image"
+	"net/http"
+	"os"
+	"testing"
+
+	"enchanted-micro/internal/userservice/avatar"
+	"enchanted-micro/internal/userservice/models"
+)
+
+func TestDeleteAvatarRemovesStoredFiles(t *testing.T) {
+	db := testDB(t)
+	store := avatar.NewStore(t.TempDir())
+	urls, err := store.Save(1, image.NewRGBA(image.Rect(0, 0, 128, 128)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{ID: 1, Username: "ayse", Email: "ayse@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&user).Select("avatar_url", "avatar_urls").
+		Updates(models.User{AvatarURL: urls[avatar.DefaultSize], AvatarURLs: urls}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The middleware loaded the user before the avatar was uploaded
+	stale := models.User{ID: 1, Username: "ayse", Email: "ayse@example.com"}
+	w := call(NewAvatarHandler(nil, store).DeleteAvatar, stale, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	for size, url := range urls {
+		f, err := store.Open(url)
+		if err == nil {
+			f.Close()
+			t.Fatalf("%s avatar still exists", size)
+		}
+		if !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	var got models.User
+	if err := db.First(&got, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.AvatarURL != "" || len(got.AvatarURLs) != 0 {
+		t.Fatalf("avatar not cleared: %q %v", got.AvatarURL, got.AvatarURLs)
+	}
+}
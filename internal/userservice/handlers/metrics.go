@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// loginAttempts - Giriş denemeleri (success / failure / blocked / disabled /
+// mfa_required / mfa_failure)
+var loginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "user_login_attempts_total",
+	Help: "Login attempts by result.",
+}, []string{"result"})
+
+// avatarUploads - Avatar yüklemeleri (success / rejected / failure)
+var avatarUploads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "user_avatar_uploads_total",
+	Help: "Avatar uploads by result.",
+}, []string{"result"})
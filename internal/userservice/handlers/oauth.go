@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/lockout"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/sso"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stateCookie - Yetkilendirmeyi başlatan tarayıcıyı callback'te tanımak için
+// state bu cookie'de de tutulur; başkasının başlattığı akış tamamlanamaz
+const stateCookie = "oauth_state"
+
+// Callback sonrası frontend'e dönülen hata kodları
+const (
+	oauthErrorDenied        = "denied"
+	oauthErrorInvalidState  = "invalid_state"
+	oauthErrorEmailRequired = "email_required"
+	oauthErrorAccountExists = "account_exists"
+	oauthErrorDisabled      = "disabled"
+	oauthErrorFailed        = "failed"
+)
+
+// OAuthProviders - Giriş için kullanılabilecek OIDC sağlayıcıları
+func (h *UserHandler) OAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.sso.Providers()})
+}
+
+// OAuthAuthorize - Tarayıcıyı sağlayıcının giriş sayfasına yönlendirir.
+// state parametresi hesap bağlama akışında OAuthLink'ten gelir; yoksa giriş
+// için yeni bir akış başlatılır.
+func (h *UserHandler) OAuthAuthorize(c *gin.Context) {
+	provider := c.Param("provider")
+	state := c.Query("state")
+	if state == "" {
+		var err error
+		state, err = h.sso.Begin(c.Request.Context(), provider, 0)
+		if errors.Is(err, sso.ErrUnknownProvider) {
+			response.Error(c, http.StatusNotFound, "Giriş sağlayıcısı bulunamadı")
+			return
+		}
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Giriş başlatılamadı")
+			return
+		}
+	}
+
+	target, err := h.sso.AuthorizationURL(c.Request.Context(), provider, state)
+	switch {
+	case errors.Is(err, sso.ErrUnknownProvider):
+		response.Error(c, http.StatusNotFound, "Giriş sağlayıcısı bulunamadı")
+		return
+	case errors.Is(err, sso.ErrInvalidState):
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorInvalidState}})
+		return
+	case err != nil:
+		logger.FromGin(c).Error("oidc authorization could not be started", "provider", provider, "error", err)
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorFailed}})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, int(h.sso.StateTTL().Seconds()), "/", "", strings.HasPrefix(h.config.OAuthBaseURL, "https://"), true)
+	c.Redirect(http.StatusFound, target)
+}
+
+// OAuthCallback - Sağlayıcının döndüğü kodla girişi tamamlar ve frontend'e
+// yönlendirir. Girişte token yerine tek kullanımlık bir kod verilir, frontend
+// bunu OAuthToken ile token çiftine çevirir. Hesap bağlamada da bir kod
+// verilir; bağlamayı oturum açmış kullanıcı LinkIdentity ile tamamlar.
+func (h *UserHandler) OAuthCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	state := c.Query("state")
+	cookie, _ := c.Cookie(stateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, "", -1, "/", "", strings.HasPrefix(h.config.OAuthBaseURL, "https://"), true)
+
+	if c.Query("error") != "" {
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorDenied}})
+		return
+	}
+	if state == "" || state != cookie {
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorInvalidState}})
+		return
+	}
+
+	result, err := h.sso.Callback(c.Request.Context(), provider, state, c.Query("code"))
+	if err != nil {
+		code := oauthErrorFailed
+		switch {
+		case errors.Is(err, sso.ErrInvalidState), errors.Is(err, sso.ErrUnknownProvider):
+			code = oauthErrorInvalidState
+		case errors.Is(err, sso.ErrEmailRequired):
+			code = oauthErrorEmailRequired
+		case errors.Is(err, sso.ErrAccountExists):
+			code = oauthErrorAccountExists
+		default:
+			logger.FromGin(c).Error("oidc callback failed", "provider", provider, "error", err)
+		}
+		h.oauthRedirect(c, url.Values{"error": {code}})
+		return
+	}
+
+	if result.LinkCode != "" {
+		h.oauthRedirect(c, url.Values{"link_code": {result.LinkCode}})
+		return
+	}
+	user := result.User
+	if user.Disabled() {
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorDisabled}})
+		return
+	}
+	if result.Created {
+		logger.FromGin(c).Info("account created with oidc", "user_id", user.ID, "provider", provider)
+		if !user.EmailVerified() {
+			if err := h.sendVerification(c, user); err != nil {
+				logger.FromGin(c).Error("email verification could not be started", "user_id", user.ID, "error", err)
+			}
+		}
+	}
+
+	code, err := h.sso.IssueLoginCode(c.Request.Context(), user.ID)
+	if err != nil {
+		logger.FromGin(c).Error("oauth login code could not be issued", "user_id", user.ID, "error", err)
+		h.oauthRedirect(c, url.Values{"error": {oauthErrorFailed}})
+		return
+	}
+	h.oauthRedirect(c, url.Values{"code": {code}})
+}
+
+// OAuthToken - Callback'in verdiği kodu Login ile aynı cevaba çevirir;
+// iki adımlı doğrulama açıksa challenge döner
+func (h *UserHandler) OAuthToken(c *gin.Context) {
+	var req models.OAuthTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.sso.RedeemLoginCode(c.Request.Context(), req.Code)
+	if errors.Is(err, sso.ErrInvalidLoginCode) {
+		response.Error(c, http.StatusUnauthorized, "Giriş kodu geçersiz ya da süresi dolmuş, tekrar giriş yapın")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+		return
+	}
+	if user.Disabled() {
+		loginAttempts.WithLabelValues("disabled").Inc()
+		response.Error(c, http.StatusForbidden, "Hesabınız devre dışı bırakılmış")
+		return
+	}
+
+	h.challengeOrLogin(c, user, lockout.Attempt{Username: user.Username, IP: c.ClientIP(), UserID: user.ID})
+}
+
+// OAuthLink - Oturum açmış kullanıcının hesabına sağlayıcı bağlamak için
+// tarayıcının açacağı adresi döner
+func (h *UserHandler) OAuthLink(c *gin.Context) {
+	provider := c.Param("provider")
+	state, err := h.sso.Begin(c.Request.Context(), provider, identity.FromContext(c).UserID)
+	if errors.Is(err, sso.ErrUnknownProvider) {
+		response.Error(c, http.StatusNotFound, "Giriş sağlayıcısı bulunamadı")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Hesap bağlama başlatılamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OAuthLinkResponse{AuthorizationURL: h.sso.AuthorizeURL(provider, state)})
+}
+
+// LinkIdentity - Callback'in verdiği bağlama koduyla sağlayıcı hesabını
+// bağlar. Kod yalnızca bağlamayı başlatan kullanıcının oturumuyla geçerlidir;
+// başkasının başlattığı bağlama linkini açan kullanıcının hesabı bağlanmaz.
+func (h *UserHandler) LinkIdentity(c *gin.Context) {
+	var req models.LinkIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := identity.FromContext(c)
+	linked, err := h.sso.Link(c.Request.Context(), id.UserID, req.Code)
+	switch {
+	case errors.Is(err, sso.ErrInvalidLinkCode):
+		logger.FromGin(c).Warn("oauth link code rejected", "user_id", id.UserID)
+		response.Error(c, http.StatusBadRequest, "Bağlama kodu geçersiz ya da süresi dolmuş, bağlamayı profilinizden yeniden başlatın")
+		return
+	case errors.Is(err, sso.ErrIdentityInUse):
+		response.Error(c, http.StatusConflict, "Bu hesap başka bir kullanıcıya bağlı")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Hesap bağlanamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("oidc identity linked", "user_id", id.UserID, "provider", linked.Provider)
+	c.JSON(http.StatusOK, gin.H{"identity": linked})
+}
+
+// ListIdentities - Kullanıcıya bağlı harici hesaplar
+func (h *UserHandler) ListIdentities(c *gin.Context) {
+	identities, err := h.sso.Identities(c.Request.Context(), identity.FromContext(c).UserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Bağlı hesaplar alınamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// UnlinkIdentity - Bağlı harici hesabı kaldır; şifresi olmayan kullanıcılar
+// son hesabı kaldıramaz
+func (h *UserHandler) UnlinkIdentity(c *gin.Context) {
+	identityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Geçersiz hesap ID")
+		return
+	}
+
+	id := identity.FromContext(c)
+	err = h.sso.Unlink(c.Request.Context(), id.UserID, uint(identityID))
+	switch {
+	case errors.Is(err, sso.ErrIdentityNotFound):
+		response.Error(c, http.StatusNotFound, "Bağlı hesap bulunamadı")
+		return
+	case errors.Is(err, sso.ErrLastLoginMethod):
+		response.Error(c, http.StatusConflict, "Giriş yapabileceğiniz tek yöntem bu; önce bir şifre belirleyin")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Hesap bağlantısı kaldırılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("oidc identity unlinked", "user_id", id.UserID, "identity_id", identityID)
+	c.JSON(http.StatusOK, gin.H{"message": "Hesap bağlantısı kaldırıldı"})
+}
+
+// oauthRedirect - Sonucu frontend'in /oauth/callback sayfasına iletir
+func (h *UserHandler) oauthRedirect(c *gin.Context, query url.Values) {
+	c.Redirect(http.StatusFound, strings.TrimSuffix(h.config.AppURL, "/")+"/oauth/callback?"+query.Encode())
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePassword - Mevcut şifre ile şifre değiştirme. Diğer tüm oturumlar
+// kapanır, bu istemciye yeni token çifti döner.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(userModel.Password), []byte(req.CurrentPassword)); err != nil {
+		response.Error(c, http.StatusForbidden, "Mevcut şifre hatalı")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Şifre hash'lenemedi")
+		return
+	}
+
+	version, err := h.tokens.ChangePassword(c.Request.Context(), userModel.ID, string(hashedPassword))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Şifre değiştirilemedi")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+
+	// Eski token'lar geçersiz, bu istemci oturumda kalsın
+	userModel.TokenVersion = version.Version
+	tokenResponse, err := h.tokens.Login(c.Request.Context(), userModel, client(c))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Token oluşturulamadı")
+		return
+	}
+
+	userModel.Password = "" // Şifreyi gizle
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		TokenResponse: tokenResponse,
+		User:          userModel,
+	})
+}
+
+// ForgotPassword - Şifre sıfırlama bağlantısı gönder. E-postanın kayıtlı olup
+// olmadığı yanıttan anlaşılmaz.
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var user models.User
+	err := database.DB.WithContext(c.Request.Context()).Where("email = ?", req.Email).First(&user).Error
+	if err == nil {
+		token, err := h.tokens.PasswordReset(c.Request.Context(), user.ID)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Şifre sıfırlama başlatılamadı")
+			return
+		}
+
+		// Gönderim arka planda yapılır, yanıt süresi e-postanın kayıtlı
+		// olduğunu belli etmesin
+		h.deliver(c, "password_reset", user.ID, func(ctx context.Context) error {
+			return h.notifier.PasswordReset(ctx, user, token)
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bu e-posta kayıtlıysa şifre sıfırlama bağlantısı gönderildi",
+	})
+}
+
+// ResetPassword - E-postadaki token ile yeni şifre belirle. Tüm oturumlar kapanır.
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Şifre hash'lenemedi")
+		return
+	}
+
+	version, err := h.tokens.ResetPassword(c.Request.Context(), req.Token, string(hashedPassword))
+	switch {
+	case errors.Is(err, tokens.ErrInvalidResetToken):
+		response.Error(c, http.StatusBadRequest, "Geçersiz veya süresi dolmuş bağlantı")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Şifre sıfırlanamadı")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Şifre başarıyla değiştirildi"})
+}
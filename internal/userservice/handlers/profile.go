@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxProfileLookup - Tek istekte sorulabilecek kullanıcı sayısı
+const maxProfileLookup = 100
+
+// profileCacheControl - Herkese açık profiller kısa süre önbelleğe alınabilir
+const profileCacheControl = "public, max-age=60"
+
+// publicUsers - Profili gösterilen kullanıcılar; devre dışı bırakılmış ve
+// silinmek üzere işaretlenmiş hesaplar görünmez
+func publicUsers(c *gin.Context) *gorm.DB {
+	return database.DB.WithContext(c.Request.Context()).
+		Where("disabled_at IS NULL AND deletion_scheduled_at IS NULL")
+}
+
+// GetPublicProfile - Kullanıcının herkese açık profili
+func (h *UserHandler) GetPublicProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		response.Error(c, http.StatusBadRequest, "Geçersiz kullanıcı ID")
+		return
+	}
+
+	var user models.User
+	err = publicUsers(c).First(&user, uint(id)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Error(c, http.StatusNotFound, "Kullanıcı bulunamadı")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Profil alınamadı")
+		return
+	}
+
+	c.Header("Cache-Control", profileCacheControl)
+	c.JSON(http.StatusOK, gin.H{"user": user.Public()})
+}
+
+// GetPublicProfiles - Birden fazla kullanıcının profili (?ids=1,2,3);
+// bulunamayan ID'ler cevapta yer almaz
+func (h *UserHandler) GetPublicProfiles(c *gin.Context) {
+	ids, ok := parseIDs(c.Query("ids"))
+	if !ok {
+		response.Error(c, http.StatusBadRequest, "ids parametresi 1 ile 100 arası kullanıcı ID'si içermeli")
+		return
+	}
+
+	var users []models.User
+	if err := publicUsers(c).Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Profiller alınamadı")
+		return
+	}
+
+	profiles := make([]models.PublicProfile, 0, len(users))
+	for _, user := range users {
+		profiles = append(profiles, user.Public())
+	}
+
+	c.Header("Cache-Control", profileCacheControl)
+	c.JSON(http.StatusOK, gin.H{"users": profiles})
+}
+
+// parseIDs - Virgülle ayrılmış ID listesi; tekrarlar atlanır
+func parseIDs(raw string) ([]uint, bool) {
+	if raw == "" {
+		return nil, false
+	}
+
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil || id == 0 {
+			return nil, false
+		}
+		if !seen[uint(id)] {
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, len(ids) <= maxProfileLookup
+}
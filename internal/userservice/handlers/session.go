@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSessions - Kullanıcının giriş yapılmış cihazları; isteği yapan oturum
+// current ile işaretlenir
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	id := identity.FromContext(c)
+	sessions, err := h.tokens.ListSessions(c.Request.Context(), id.UserID, id.SessionID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Oturumlar alınamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession - Bir cihazdaki oturumu sonlandır; o cihazın token'ları
+// artık kabul edilmez
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	id := identity.FromContext(c)
+	session, err := h.tokens.RevokeSession(c.Request.Context(), id.UserID, c.Param("id"))
+	if errors.Is(err, tokens.ErrSessionNotFound) {
+		response.Error(c, http.StatusNotFound, "Oturum bulunamadı")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Oturum sonlandırılamadı")
+		return
+	}
+	h.revocations.AddSessions([]revocation.Session{session})
+
+	logger.FromGin(c).Info("session revoked", "user_id", id.UserID, "session_id", session.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Oturum sonlandırıldı"})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/lockout"
+	"enchanted-micro/internal/userservice/mfa"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// EnrollTwoFactor - Yeni TOTP anahtarı oluştur. İki adımlı doğrulama, anahtar
+// ConfirmTwoFactor ile bir kodla onaylanınca açılır.
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+
+	enrollment, err := h.mfa.Enroll(c.Request.Context(), user.(models.User))
+	if errors.Is(err, mfa.ErrAlreadyEnabled) {
+		response.Error(c, http.StatusConflict, "İki adımlı doğrulama zaten açık")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "İki adımlı doğrulama başlatılamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor - Authenticator uygulamasındaki kodla kurulumu tamamla;
+// kurtarma kodları sadece bu cevapta gösterilir
+func (h *UserHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req models.ConfirmTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := identity.FromContext(c)
+	codes, err := h.mfa.Confirm(c.Request.Context(), id.UserID, req.Code)
+	switch {
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		response.Error(c, http.StatusBadRequest, "Önce iki adımlı doğrulama kurulumunu başlatın")
+		return
+	case errors.Is(err, mfa.ErrAlreadyEnabled):
+		response.Error(c, http.StatusConflict, "İki adımlı doğrulama zaten açık")
+		return
+	case errors.Is(err, mfa.ErrInvalidCode):
+		response.Error(c, http.StatusBadRequest, "Geçersiz doğrulama kodu")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "İki adımlı doğrulama açılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("two-factor authentication enabled", "user_id", id.UserID)
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor - Şifre ve geçerli bir kodla iki adımlı doğrulamayı kapat;
+// şifresi olmayan hesaplarda kod yeterlidir
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Sadece harici sağlayıcıyla giriş yapan hesapların şifresi yoktur
+	if userModel.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(userModel.Password), []byte(req.Password)); err != nil {
+			response.Error(c, http.StatusForbidden, "Şifre hatalı")
+			return
+		}
+	}
+	err := h.mfa.Verify(c.Request.Context(), userModel.ID, req.Code)
+	switch {
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		response.Error(c, http.StatusBadRequest, "İki adımlı doğrulama açık değil")
+		return
+	case errors.Is(err, mfa.ErrInvalidCode):
+		response.Error(c, http.StatusForbidden, "Geçersiz doğrulama kodu")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Kod doğrulanamadı")
+		return
+	}
+
+	if err := h.mfa.Disable(c.Request.Context(), userModel.ID, userModel.ID); err != nil {
+		response.Error(c, http.StatusInternalServerError, "İki adımlı doğrulama kapatılamadı")
+		return
+	}
+
+	logger.FromGin(c).Info("two-factor authentication disabled", "user_id", userModel.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "İki adımlı doğrulama kapatıldı"})
+}
+
+// RegenerateRecoveryCodes - Geçerli bir kodla yeni kurtarma kodları oluştur;
+// eskiler geçersiz olur
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.ConfirmTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := identity.FromContext(c)
+	codes, err := h.mfa.RecoveryCodes(c.Request.Context(), id.UserID, req.Code)
+	switch {
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		response.Error(c, http.StatusBadRequest, "İki adımlı doğrulama açık değil")
+		return
+	case errors.Is(err, mfa.ErrInvalidCode):
+		response.Error(c, http.StatusForbidden, "Geçersiz doğrulama kodu")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Kurtarma kodları oluşturulamadı")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginTwoFactor - Girişin ikinci adımı: Login'in döndüğü mfa_token ve TOTP ya
+// da kurtarma koduyla token çifti alınır
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.mfa.Pending(c.Request.Context(), req.MFAToken)
+	if errors.Is(err, mfa.ErrInvalidChallenge) {
+		response.Error(c, http.StatusUnauthorized, "Doğrulama oturumu geçersiz ya da süresi dolmuş, tekrar giriş yapın")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+		return
+	}
+
+	// Yanlış kodlar şifre denemeleri gibi sayılır
+	attempt := lockout.Attempt{Username: user.Username, IP: c.ClientIP(), UserID: user.ID}
+	wait, err := h.lockout.Check(c.Request.Context(), attempt)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+		return
+	}
+	if wait > 0 {
+		blocked(c, wait)
+		return
+	}
+
+	user, err = h.mfa.Redeem(c.Request.Context(), req.MFAToken, req.Code)
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		loginAttempts.WithLabelValues("mfa_failure").Inc()
+		if err := h.lockout.Failure(c.Request.Context(), attempt); err != nil {
+			logger.FromGin(c).Error("login failure could not be recorded", "error", err)
+		}
+		response.Error(c, http.StatusUnauthorized, "Geçersiz doğrulama kodu")
+		return
+	case errors.Is(err, mfa.ErrInvalidChallenge):
+		response.Error(c, http.StatusUnauthorized, "Doğrulama oturumu geçersiz ya da süresi dolmuş, tekrar giriş yapın")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+		return
+	}
+
+	// Şifre adımından sonra devre dışı bırakılmış olabilir
+	if user.Disabled() {
+		loginAttempts.WithLabelValues("disabled").Inc()
+		response.Error(c, http.StatusForbidden, "Hesabınız devre dışı bırakılmış")
+		return
+	}
+
+	h.completeLogin(c, user, attempt)
+}
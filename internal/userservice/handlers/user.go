@@ -1,38 +1,66 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/pkg/revocation"
 	"enchanted-micro/internal/userservice/config"
 	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/lockout"
+	"enchanted-micro/internal/userservice/mfa"
 	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/notify"
+	"enchanted-micro/internal/userservice/sso"
+	"enchanted-micro/internal/userservice/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	config *config.Config
+	config      *config.Config
+	tokens      *tokens.Issuer
+	revocations *revocation.Cache
+	notifier    notify.Notifier
+	lockout     *lockout.Guard
+	mfa         *mfa.Manager
+	sso         *sso.Manager
 }
 
-func NewUserHandler(cfg *config.Config) *UserHandler {
-	return &UserHandler{config: cfg}
+func NewUserHandler(cfg *config.Config, issuer *tokens.Issuer, revocations *revocation.Cache, notifier notify.Notifier, guard *lockout.Guard, twoFactor *mfa.Manager, signOn *sso.Manager) *UserHandler {
+	return &UserHandler{config: cfg, tokens: issuer, revocations: revocations, notifier: notifier, lockout: guard, mfa: twoFactor, sso: signOn}
+}
+
+// dummyHash - Olmayan kullanıcı adlarında da şifre karşılaştırması yapılır,
+// böylece cevap süresinden hesabın var olup olmadığı anlaşılamaz
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("enchanted-dummy-password"), bcrypt.DefaultCost)
+
+// client - Token'ın verildiği istemci bilgisi
+func client(c *gin.Context) tokens.Client {
+	return tokens.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
 }
 
 // Register - Yeni kullanıcı kaydı
 func (h *UserHandler) Register(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Şifreyi hash'le
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Şifre hash'lenemedi"})
+		response.Error(c, http.StatusInternalServerError, "Şifre hash'lenemedi")
 		return
 	}
 
@@ -41,19 +69,25 @@ func (h *UserHandler) Register(c *gin.Context) {
 		Username: req.Username,
 		Password: string(hashedPassword),
 		Email:    req.Email,
+		Roles:    []string{identity.RoleUser},
 	}
 
 	// Veritabanına kaydet
-	if err := database.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Kullanıcı adı veya email zaten kullanılıyor"})
+	if err := database.DB.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
+		response.Error(c, http.StatusConflict, "Kullanıcı adı veya email zaten kullanılıyor")
 		return
 	}
 
+	// Doğrulama e-postası; gönderilemezse kullanıcı daha sonra tekrar isteyebilir
+	if err := h.sendVerification(c, user); err != nil {
+		logger.FromGin(c).Error("email verification could not be started", "user_id", user.ID, "error", err)
+	}
+
 	// Şifreyi response'dan çıkar
 	user.Password = ""
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Kullanıcı başarıyla oluşturuldu",
+		"message": "Kullanıcı başarıyla oluşturuldu, e-posta adresinizi doğrulayın",
 		"user":    user,
 	})
 }
@@ -62,50 +96,191 @@ func (h *UserHandler) Register(c *gin.Context) {
 func (h *UserHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Çok fazla başarısız deneme yapılan kullanıcı adı ya da IP beklemeye alınır
+	attempt := lockout.Attempt{Username: req.Username, IP: c.ClientIP()}
+	wait, err := h.lockout.Check(c.Request.Context(), attempt)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+		return
+	}
+	if wait > 0 {
+		blocked(c, wait)
 		return
 	}
 
-	// Kullanıcıyı bul
+	// Kullanıcıyı bul; bulunamazsa da şifre karşılaştırması yapılır
 	var user models.User
-	if err := database.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz kullanıcı adı veya şifre"})
+	hashedPassword := dummyHash
+	err = database.DB.WithContext(c.Request.Context()).Where("username = ?", req.Username).First(&user).Error
+	if err == nil {
+		hashedPassword = []byte(user.Password)
+		attempt.UserID = user.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
 		return
 	}
 
 	// Şifreyi kontrol et
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz kullanıcı adı veya şifre"})
+	if err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(req.Password)); err != nil || attempt.UserID == 0 {
+		loginAttempts.WithLabelValues("failure").Inc()
+		if err := h.lockout.Failure(c.Request.Context(), attempt); err != nil {
+			logger.FromGin(c).Error("login failure could not be recorded", "error", err)
+		}
+		response.Error(c, http.StatusUnauthorized, "Geçersiz kullanıcı adı veya şifre")
 		return
 	}
 
-	// JWT token oluştur
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24 saat geçerli
-	})
+	// Devre dışı bırakılmış hesaplar giriş yapamaz
+	if user.Disabled() {
+		loginAttempts.WithLabelValues("disabled").Inc()
+		response.Error(c, http.StatusForbidden, "Hesabınız devre dışı bırakılmış")
+		return
+	}
+
+	h.challengeOrLogin(c, user, attempt)
+}
 
-	tokenString, err := token.SignedString([]byte(h.config.JWTSecret))
+// blocked - Beklemeye alınmış giriş denemesine 429 döner
+func blocked(c *gin.Context, wait time.Duration) {
+	loginAttempts.WithLabelValues("blocked").Inc()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response.Error(c, http.StatusTooManyRequests, "Çok fazla başarısız giriş denemesi, lütfen daha sonra tekrar deneyin")
+}
+
+// challengeOrLogin - Kimliği doğrulanmış kullanıcıya token çiftini döner;
+// iki adımlı doğrulama açıksa token yerine kısa ömürlü bir challenge döner
+func (h *UserHandler) challengeOrLogin(c *gin.Context, user models.User, attempt lockout.Attempt) {
+	if user.TwoFactorEnabled {
+		token, err := h.mfa.Challenge(c.Request.Context(), user.ID)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Giriş yapılamadı")
+			return
+		}
+		loginAttempts.WithLabelValues("mfa_required").Inc()
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresIn:   int64(h.mfa.ChallengeTTL().Seconds()),
+		})
+		return
+	}
+
+	h.completeLogin(c, user, attempt)
+}
+
+// completeLogin - Başarılı girişte sayaçları sıfırlar ve token çiftini döner
+func (h *UserHandler) completeLogin(c *gin.Context, user models.User, attempt lockout.Attempt) {
+	if err := h.lockout.Success(c.Request.Context(), attempt); err != nil {
+		logger.FromGin(c).Error("login failures could not be cleared", "user_id", user.ID, "error", err)
+	}
+
+	// Access ve refresh token oluştur
+	tokenResponse, err := h.tokens.Login(c.Request.Context(), user, client(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token oluşturulamadı"})
+		response.Error(c, http.StatusInternalServerError, "Token oluşturulamadı")
 		return
 	}
 
+	loginAttempts.WithLabelValues("success").Inc()
+
 	// Şifreyi response'dan çıkar
 	user.Password = ""
 
 	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: tokenString,
-		User:  user,
+		TokenResponse: tokenResponse,
+		User:          user,
 	})
 }
 
+// Refresh - Refresh token ile yeni access token al (token rotasyonu)
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokenResponse, user, err := h.tokens.Refresh(c.Request.Context(), req.RefreshToken, client(c))
+	switch {
+	case errors.Is(err, tokens.ErrRefreshTokenReused):
+		logger.FromGin(c).Warn("refresh token reuse detected, token family revoked", "user_id", user.ID)
+		response.Error(c, http.StatusUnauthorized, "Geçersiz refresh token")
+		return
+	case errors.Is(err, tokens.ErrInvalidRefreshToken):
+		response.Error(c, http.StatusUnauthorized, "Geçersiz refresh token")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Token yenilenemedi")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse)
+}
+
+// Logout - Mevcut access token'ı, oturumu ve (gönderildiyse) refresh token ailesini iptal et
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	id := identity.FromContext(c)
+	revoked, err := h.tokens.RevokeAccess(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Çıkış yapılamadı")
+		return
+	}
+	if revoked.ID != "" {
+		h.revocations.Add([]revocation.Token{revoked}, nil)
+	}
+
+	if id.SessionID != "" {
+		session, err := h.tokens.RevokeSession(c.Request.Context(), id.UserID, id.SessionID)
+		switch {
+		case errors.Is(err, tokens.ErrSessionNotFound):
+		case err != nil:
+			response.Error(c, http.StatusInternalServerError, "Çıkış yapılamadı")
+			return
+		default:
+			h.revocations.AddSessions([]revocation.Session{session})
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.tokens.RevokeFamily(c.Request.Context(), id.UserID, req.RefreshToken); err != nil {
+			response.Error(c, http.StatusInternalServerError, "Çıkış yapılamadı")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Çıkış yapıldı"})
+}
+
+// LogoutAll - Tüm cihazlardan çıkış: kullanıcının tüm token'ları geçersiz olur
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	id := identity.FromContext(c)
+	version, err := h.tokens.RevokeAll(c.Request.Context(), id.UserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Çıkış yapılamadı")
+		return
+	}
+	h.revocations.Add(nil, []revocation.UserVersion{version})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tüm cihazlardan çıkış yapıldı"})
+}
+
 // GetProfile - Kullanıcı profili
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
@@ -119,31 +294,46 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 		return
 	}
 
 	userModel := user.(models.User)
 
-	var req struct {
-		Email string `json:"email" binding:"email"`
-	}
+	var req models.UpdateProfileRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Email güncelle
-	if req.Email != "" {
+	// Herkese açık profil alanları
+	setTrimmed(&userModel.DisplayName, req.DisplayName)
+	setTrimmed(&userModel.Bio, req.Bio)
+	setTrimmed(&userModel.Location, req.Location)
+
+	// Email güncelle; yeni adres tekrar doğrulanmalı
+	emailChanged := req.Email != "" && req.Email != userModel.Email
+	if emailChanged {
 		userModel.Email = req.Email
+		userModel.EmailVerifiedAt = nil
 	}
 
-	if err := database.DB.Save(&userModel).Error; err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email zaten kullanılıyor"})
+	// Yalnızca profil sütunları yazılır; istek sürerken değişen şifre, rol
+	// ya da token sürümü eski kopyayla ezilmez
+	if err := database.DB.WithContext(c.Request.Context()).Model(&userModel).
+		Select("email", "email_verified_at", "display_name", "bio", "location").
+		Updates(&userModel).Error; err != nil {
+		response.Error(c, http.StatusConflict, "Email zaten kullanılıyor")
 		return
 	}
 
+	if emailChanged {
+		if err := h.sendVerification(c, userModel); err != nil {
+			logger.FromGin(c).Error("email verification could not be started", "user_id", userModel.ID, "error", err)
+		}
+	}
+
 	userModel.Password = "" // Şifreyi gizle
 
 	c.JSON(http.StatusOK, gin.H{
@@ -151,3 +341,10 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		"user":    userModel,
 	})
 }
+
+// setTrimmed - Gönderilen alanı boşlukları atarak yaz
+func setTrimmed(field *string, value *string) {
+	if value != nil {
+		*field = strings.TrimSpace(*value)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/database"
+	"enchanted-micro/internal/userservice/mfa"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// testDB points database.DB at an in-memory database with the given tables
+func testDB(t *testing.T, tables ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(append([]any{&models.User{}}, tables...)...); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	return db
+}
+
+// call runs handler with body as JSON and user set as by the middleware
+func call(handler gin.HandlerFunc, user models.User, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user", user)
+	handler(c)
+	return w
+}
+
+func TestUpdateProfileWritesOnlyProfileColumns(t *testing.T) {
+	db := testDB(t)
+
+	user := models.User{Username: "ayse", Email: "ayse@example.com", Password: "old-hash", Roles: []string{identity.RoleUser}}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	// The request carries the user as loaded by the auth middleware; the
+	// password and roles change before the profile is saved
+	stale := user
+	if err := db.Model(&user).Updates(map[string]any{"password": "new-hash", "roles": `["user","admin"]`, "token_version": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := call((&UserHandler{}).UpdateProfile, stale, `{"display_name":" Ayşe ","bio":"Koleksiyoncu"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var got models.User
+	if err := db.First(&got, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.DisplayName != "Ayşe" || got.Bio != "Koleksiyoncu" {
+		t.Fatalf("profile %q %q", got.DisplayName, got.Bio)
+	}
+	if got.Password != "new-hash" || got.TokenVersion != 1 || !slices.Contains(got.Roles, identity.RoleAdmin) {
+		t.Fatalf("non-profile columns overwritten: password %q version %d roles %v", got.Password, got.TokenVersion, got.Roles)
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string // stored hash is made from secret-password when set
+		body     string
+		status   int
+		disabled bool
+	}{
+		{name: "password account", password: string(hash), body: `{"password":"secret-password","code":"%s"}`, status: http.StatusOK, disabled: true},
+		{name: "wrong password", password: string(hash), body: `{"password":"wrong","code":"%s"}`, status: http.StatusForbidden},
+		{name: "password missing", password: string(hash), body: `{"code":"%s"}`, status: http.StatusForbidden},
+		// Accounts created through OIDC have no password; the code suffices
+		{name: "oidc account", body: `{"code":"%s"}`, status: http.StatusOK, disabled: true},
+		{name: "oidc account wrong code", body: `{"code":"00000-00000"}`, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testDB(t, &models.TwoFactor{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.AuditLog{})
+			twoFactor := mfa.NewManager(db, &config.Config{TOTPIssuer: "Enchanted"})
+
+			user := models.User{Username: "ayse", Email: "ayse@example.com", Password: tt.password}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			enrollment, err := twoFactor.Enroll(ctx, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			recovery, err := twoFactor.Confirm(ctx, user.ID, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.TwoFactorEnabled = true
+
+			body := tt.body
+			if strings.Contains(body, "%s") {
+				body = fmt.Sprintf(body, recovery[0])
+			}
+			w := call((&UserHandler{mfa: twoFactor}).DisableTwoFactor, user, body)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			var got models.User
+			if err := db.First(&got, user.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got.TwoFactorEnabled == tt.disabled {
+				t.Fatalf("two_factor_enabled %v", got.TwoFactorEnabled)
+			}
+		})
+	}
+}
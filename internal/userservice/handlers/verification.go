@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"enchanted-micro/internal/pkg/logger"
+	"enchanted-micro/internal/pkg/response"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail - E-postadaki token ile adresi doğrula. Token GET'te query'den,
+// POST'ta body'den okunur.
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if c.Request.Method == http.MethodPost {
+		var req models.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		response.Error(c, http.StatusBadRequest, "Token gerekli")
+		return
+	}
+
+	user, err := h.tokens.VerifyEmail(c.Request.Context(), token)
+	switch {
+	case errors.Is(err, tokens.ErrInvalidVerificationToken):
+		response.Error(c, http.StatusBadRequest, "Geçersiz veya süresi dolmuş bağlantı")
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "E-posta doğrulanamadı")
+		return
+	}
+
+	user.Password = "" // Şifreyi gizle
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "E-posta adresi doğrulandı",
+		"user":    user,
+	})
+}
+
+// ResendVerification - Doğrulama e-postasını tekrar gönder
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
+		return
+	}
+	userModel := user.(models.User)
+
+	if userModel.EmailVerified() {
+		response.Error(c, http.StatusConflict, "E-posta adresi zaten doğrulanmış")
+		return
+	}
+
+	if err := h.sendVerification(c, userModel); err != nil {
+		if errors.Is(err, tokens.ErrVerificationThrottled) {
+			c.Header("Retry-After", strconv.Itoa(int(h.config.EmailVerificationResend/time.Second)))
+			response.Error(c, http.StatusTooManyRequests, "Doğrulama e-postası kısa süre önce gönderildi, lütfen bekleyin")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Doğrulama e-postası gönderilemedi")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Doğrulama e-postası gönderildi"})
+}
+
+// sendVerification - Doğrulama token'ı oluşturup e-postayı arka planda gönderir
+func (h *UserHandler) sendVerification(c *gin.Context, user models.User) error {
+	token, err := h.tokens.EmailVerification(c.Request.Context(), user)
+	if err != nil {
+		return err
+	}
+	h.deliver(c, "email_verification", user.ID, func(ctx context.Context) error {
+		return h.notifier.EmailVerification(ctx, user, token)
+	})
+	return nil
+}
+
+// deliver - Bildirimi istekten bağımsız olarak arka planda gönderir; hata
+// sadece loglanır
+func (h *UserHandler) deliver(c *gin.Context, kind string, userID uint, send func(ctx context.Context) error) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	log := logger.FromGin(c)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Error("notification failed", "kind", kind, "user_id", userID, "error", err)
+		}
+	}()
+}
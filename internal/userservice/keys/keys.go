@@ -0,0 +1,270 @@
+// Package keys manages the asymmetric keys access tokens are signed with.
+// Keys live in the user database so every instance signs with the same key
+// and rotation happens once for the whole service.
+//
+// A rotated-in key is published in the JWKS for a while before it starts
+// signing, so verifiers with a cached key set know it by the time tokens
+// signed with it arrive. The key it replaces stays published until every
+// token it signed has expired.
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"enchanted-micro/internal/pkg/jwks"
+	"enchanted-micro/internal/pkg/secretbox"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// prepublish is how long a new key is published before it signs
+const prepublish = 2 * jwks.CacheTTL
+
+var ErrNoActiveKey = errors.New("no active signing key")
+
+// Manager signs with the active key and resolves public keys by kid
+type Manager struct {
+	db          *gorm.DB
+	box         *secretbox.Box
+	algorithm   string
+	rotateEvery time.Duration
+	tokenTTL    time.Duration
+
+	mu   sync.RWMutex
+	keys []key // newest activation first
+}
+
+type key struct {
+	models.SigningKey
+	private crypto.Signer
+}
+
+// NewManager loads the signing keys, creating the first one if the table
+// is empty
+func NewManager(ctx context.Context, db *gorm.DB, cfg *config.Config) (*Manager, error) {
+	switch cfg.JWTAlgorithm {
+	case jwks.AlgRS256, jwks.AlgEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.JWTAlgorithm)
+	}
+
+	m := &Manager{
+		db:          db,
+		box:         secretbox.New(cfg.DataEncryptionKey),
+		algorithm:   cfg.JWTAlgorithm,
+		rotateEvery: cfg.KeyRotationInterval,
+		tokenTTL:    cfg.AccessTokenTTL,
+	}
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	if len(m.keys) == 0 {
+		if err := m.create(ctx, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := m.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Signing returns the key new tokens are signed with
+func (m *Manager) Signing() (kid string, method jwt.SigningMethod, private crypto.Signer, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range m.keys {
+		if !k.ActivatesAt.After(now) {
+			return k.KID, jwt.GetSigningMethod(k.Algorithm), k.private, nil
+		}
+	}
+	return "", nil, nil, ErrNoActiveKey
+}
+
+// PublicKey implements identity.Keys
+func (m *Manager) PublicKey(_ context.Context, kid string) (crypto.PublicKey, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if k.KID == kid {
+			return k.private.Public(), k.Algorithm, nil
+		}
+	}
+	return nil, "", jwks.ErrUnknownKey
+}
+
+// Published returns the keys served at /.well-known/jwks.json
+func (m *Manager) Published() ([]jwks.Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]jwks.Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, jwks.Key{ID: k.KID, Algorithm: k.Algorithm, Public: k.private.Public()})
+	}
+	return keys, nil
+}
+
+// Run reloads keys written by other instances, rotates when the active key
+// is due and drops expired keys, every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.maintain(ctx, time.Now()); err != nil {
+			slog.Error("signing key maintenance failed", "error", err)
+		}
+	}
+}
+
+func (m *Manager) maintain(ctx context.Context, now time.Time) error {
+	if err := m.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.SigningKey{}).Error; err != nil {
+		return err
+	}
+	if err := m.load(ctx); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	if len(m.keys) == 0 {
+		m.mu.RUnlock()
+		return m.create(ctx, now)
+	}
+	newest := m.keys[0]
+	m.mu.RUnlock()
+
+	due := newest.ActivatesAt.Add(m.rotateEvery - prepublish)
+	if newest.Algorithm == m.algorithm && now.Before(due) {
+		return nil
+	}
+	if newest.ActivatesAt.After(now) {
+		// A successor is already waiting to activate
+		return nil
+	}
+
+	activates := now.Add(prepublish)
+	if err := m.create(ctx, activates); err != nil {
+		return err
+	}
+	// The old key stops signing when the new one activates; its tokens
+	// stay verifiable until they expire
+	expires := activates.Add(m.tokenTTL + prepublish)
+	if err := m.db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("kid = ? AND expires_at IS NULL", newest.KID).
+		Update("expires_at", expires).Error; err != nil {
+		return err
+	}
+	return m.load(ctx)
+}
+
+// create generates and stores a key that starts signing at activates
+func (m *Manager) create(ctx context.Context, activates time.Time) error {
+	var private crypto.Signer
+	var err error
+	switch m.algorithm {
+	case jwks.AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	sealed, err := m.box.Seal(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return err
+	}
+
+	record := models.SigningKey{
+		KID:         uuid.NewString(),
+		Algorithm:   m.algorithm,
+		PrivateKey:  sealed,
+		ActivatesAt: activates,
+	}
+	if err := m.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return err
+	}
+	slog.Info("signing key created", "kid", record.KID, "algorithm", record.Algorithm, "activates_at", activates)
+	return nil
+}
+
+func (m *Manager) load(ctx context.Context) error {
+	var records []models.SigningKey
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys := make([]key, 0, len(records))
+	for _, r := range records {
+		if r.ExpiresAt != nil && r.ExpiresAt.Before(now) {
+			continue
+		}
+		private, err := m.parse(r)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", r.KID, err)
+		}
+		keys = append(keys, key{SigningKey: r, private: private})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ActivatesAt.After(keys[j].ActivatesAt) })
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) parse(r models.SigningKey) (crypto.Signer, error) {
+	data, err := m.box.Open(r.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, jwks.ErrUnsupportedKey
+	}
+	if alg, err := jwks.Algorithm(private.Public()); err != nil || alg != r.Algorithm {
+		return nil, jwks.ErrUnsupportedKey
+	}
+	return private, nil
+}
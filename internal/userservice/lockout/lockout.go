@@ -0,0 +1,218 @@
+// Package lockout slows down and temporarily blocks password guessing on
+// login. Failures are counted per username and per client IP in the
+// database, so every instance of the service sees the same counters.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit log events written by the guard
+const (
+	EventAccountLocked   = "account_locked"
+	EventIPLocked        = "ip_locked"
+	EventAccountUnlocked = "account_unlocked"
+)
+
+// Attempt identifies a login attempt. UserID is zero when the username
+// does not exist; such attempts are counted all the same so lockouts do
+// not reveal which accounts exist.
+type Attempt struct {
+	Username string
+	IP       string
+	UserID   uint
+}
+
+// policy describes how failures for one kind of key are handled
+type policy struct {
+	prefix string
+	event  string
+	max    int
+	// delay makes every failure below max block the key for a growing
+	// interval; IPs only lock so users behind a shared address are not
+	// slowed down by each other's typos
+	delay bool
+}
+
+// Guard tracks failed logins and decides whether a new attempt may proceed
+type Guard struct {
+	db        *gorm.DB
+	account   policy
+	ip        policy
+	window    time.Duration
+	lockout   time.Duration
+	delayBase time.Duration
+	delayMax  time.Duration
+	now       func() time.Time
+}
+
+// NewGuard builds a guard from the service config
+func NewGuard(db *gorm.DB, cfg *config.Config) *Guard {
+	return &Guard{
+		db:        db,
+		account:   policy{prefix: "user:", event: EventAccountLocked, max: cfg.LoginMaxAttempts, delay: true},
+		ip:        policy{prefix: "ip:", event: EventIPLocked, max: cfg.LoginIPMaxAttempts},
+		window:    cfg.LoginFailureWindow,
+		lockout:   cfg.LoginLockoutDuration,
+		delayBase: cfg.LoginDelayBase,
+		delayMax:  cfg.LoginDelayMax,
+		now:       time.Now,
+	}
+}
+
+// Check returns how long the caller has to wait before the attempt may be
+// made; zero means it may proceed
+func (g *Guard) Check(ctx context.Context, a Attempt) (time.Duration, error) {
+	now := g.now()
+	var blocked []models.LoginFailure
+	err := g.db.WithContext(ctx).
+		Where("key IN ? AND blocked_until > ?", []string{g.account.prefix + a.Username, g.ip.prefix + a.IP}, now).
+		Find(&blocked).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, f := range blocked {
+		wait = max(wait, f.BlockedUntil.Sub(now))
+	}
+	return wait, nil
+}
+
+// Failure counts a failed attempt against both the username and the IP.
+// Reaching a policy's limit locks the key and writes an audit record.
+func (g *Guard) Failure(ctx context.Context, a Attempt) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := g.record(tx, g.account, a.Username, a); err != nil {
+			return err
+		}
+		return g.record(tx, g.ip, a.IP, a)
+	})
+}
+
+// Success clears the username's failures. The IP counter is left to expire
+// so one valid account cannot be used to reset it.
+func (g *Guard) Success(ctx context.Context, a Attempt) error {
+	return g.db.WithContext(ctx).
+		Where(&models.LoginFailure{Key: g.account.prefix + a.Username}).
+		Delete(&models.LoginFailure{}).Error
+}
+
+// Unlock lifts a lockout or delay on user's account before it runs out
+func (g *Guard) Unlock(ctx context.Context, user models.User, actorID uint) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where(&models.LoginFailure{Key: g.account.prefix + user.Username}).Delete(&models.LoginFailure{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Create(&models.AuditLog{
+			Event:   EventAccountUnlocked,
+			UserID:  &user.ID,
+			ActorID: &actorID,
+			Subject: user.Username,
+		}).Error
+	})
+}
+
+// PurgeExpired deletes counters that no longer block anything every
+// interval until ctx is done
+func (g *Guard) PurgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res := g.db.WithContext(ctx).Where("expires_at < ?", g.now()).Delete(&models.LoginFailure{})
+		if res.Error != nil {
+			slog.Warn("login failure purge failed", "error", res.Error)
+		} else if res.RowsAffected > 0 {
+			slog.Info("login failures purged", "count", res.RowsAffected)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record increments the counter for subject under p inside tx
+func (g *Guard) record(tx *gorm.DB, p policy, subject string, a Attempt) error {
+	now := g.now()
+	key := p.prefix + subject
+
+	// The row is created first so concurrent failures serialize on its lock
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginFailure{Key: key, BlockedUntil: now, ExpiresAt: now}).Error
+	if err != nil {
+		return err
+	}
+	var f models.LoginFailure
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&models.LoginFailure{Key: key}).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Purged or unlocked between the two statements; nothing to add to
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !now.Before(f.ExpiresAt) {
+		f.Failures = 0
+		f.LockedAt = nil
+	}
+	f.Failures++
+	f.BlockedUntil = now
+	f.ExpiresAt = now.Add(g.window)
+
+	locked := p.max > 0 && f.Failures >= p.max
+	switch {
+	case locked:
+		f.LockedAt = &now
+		f.BlockedUntil = now.Add(g.lockout)
+		// The count starts over once the lockout ends
+		f.ExpiresAt = f.BlockedUntil
+	case p.delay:
+		f.BlockedUntil = now.Add(g.delay(f.Failures))
+		if f.BlockedUntil.After(f.ExpiresAt) {
+			f.ExpiresAt = f.BlockedUntil
+		}
+	}
+	if err := tx.Save(&f).Error; err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	slog.Warn("login locked", "key", key, "failures", f.Failures, "until", f.BlockedUntil)
+	entry := models.AuditLog{
+		Event:   p.event,
+		Subject: subject,
+		IP:      a.IP,
+		Detail:  fmt.Sprintf("%d failed attempts, locked for %s", f.Failures, g.lockout),
+	}
+	if p.event == EventAccountLocked && a.UserID != 0 {
+		entry.UserID = &a.UserID
+	}
+	return tx.Create(&entry).Error
+}
+
+// delay doubles with every failure from delayBase up to delayMax
+func (g *Guard) delay(failures int) time.Duration {
+	d := g.delayBase
+	for i := 1; i < failures && d < g.delayMax; i++ {
+		d *= 2
+	}
+	return min(d, g.delayMax)
+}
@@ -0,0 +1,184 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// clock is a settable time source for guards under test
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+// newTestGuard locks accounts after 3 failures and IPs after 5, delaying
+// account failures by 1s, 2s, 4s...
+func newTestGuard(t *testing.T) (*Guard, *clock, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.LoginFailure{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGuard(db, &config.Config{
+		LoginMaxAttempts:     3,
+		LoginIPMaxAttempts:   5,
+		LoginFailureWindow:   15 * time.Minute,
+		LoginLockoutDuration: 10 * time.Minute,
+		LoginDelayBase:       time.Second,
+		LoginDelayMax:        30 * time.Second,
+	})
+	c := &clock{t: time.Unix(1700000000, 0)}
+	g.now = c.now
+	return g, c, db
+}
+
+// login is one attempt against a guard: after advancing the clock, Check
+// must return wait; a non-success attempt then counts as a failure
+type login struct {
+	advance  time.Duration
+	username string
+	ip       string
+	wait     time.Duration
+	success  bool
+}
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		logins []login
+	}{
+		{
+			name: "delays grow until the account locks",
+			logins: []login{
+				{username: "ayse", ip: "198.51.100.1"},
+				{username: "ayse", ip: "198.51.100.1", wait: time.Second},
+				{advance: time.Second, username: "ayse", ip: "198.51.100.1"},
+				{username: "ayse", ip: "198.51.100.1", wait: 2 * time.Second},
+				{advance: 2 * time.Second, username: "ayse", ip: "198.51.100.1"},
+				// Locked from every address
+				{username: "ayse", ip: "198.51.100.2", wait: 10 * time.Minute},
+				{advance: 10 * time.Minute, username: "ayse", ip: "198.51.100.1", success: true},
+			},
+		},
+		{
+			name: "delays do not apply to the ip",
+			logins: []login{
+				{username: "ayse", ip: "198.51.100.1"},
+				{username: "mehmet", ip: "198.51.100.1", success: true},
+			},
+		},
+		{
+			name: "success clears the account but not the ip",
+			logins: []login{
+				{username: "ayse", ip: "198.51.100.1"},
+				{advance: time.Second, username: "ayse", ip: "198.51.100.1"},
+				{advance: 2 * time.Second, username: "ayse", ip: "198.51.100.1", success: true},
+				{username: "ayse", ip: "198.51.100.1"},
+				{advance: time.Second, username: "ayse", ip: "198.51.100.1"},
+				// Fifth failure from this address; unknown usernames count too
+				{advance: 2 * time.Second, username: "nobody", ip: "198.51.100.1"},
+				{username: "mehmet", ip: "198.51.100.1", wait: 10 * time.Minute},
+				{username: "mehmet", ip: "198.51.100.2", success: true},
+			},
+		},
+		{
+			name: "failures expire after the window",
+			logins: []login{
+				{username: "ayse", ip: "198.51.100.1"},
+				{advance: time.Second, username: "ayse", ip: "198.51.100.1"},
+				{advance: 15 * time.Minute, username: "ayse", ip: "198.51.100.1"},
+				// Counting started over, so this is the second failure
+				{advance: time.Second, username: "ayse", ip: "198.51.100.1"},
+				{username: "ayse", ip: "198.51.100.1", wait: 2 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, c, _ := newTestGuard(t)
+			ctx := context.Background()
+			for i, l := range tt.logins {
+				c.t = c.t.Add(l.advance)
+				a := Attempt{Username: l.username, IP: l.ip}
+				wait, err := g.Check(ctx, a)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wait != l.wait {
+					t.Fatalf("login %d: wait %s, want %s", i, wait, l.wait)
+				}
+				if wait > 0 {
+					continue
+				}
+				if l.success {
+					err = g.Success(ctx, a)
+				} else {
+					err = g.Failure(ctx, a)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLockAndUnlockAreAudited(t *testing.T) {
+	g, _, db := newTestGuard(t)
+	ctx := context.Background()
+	user := models.User{ID: 7, Username: "ayse"}
+
+	for range 3 {
+		if err := g.Failure(ctx, Attempt{Username: "ayse", IP: "198.51.100.1", UserID: user.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Unlock(ctx, user, 1); err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := g.Check(ctx, Attempt{Username: "ayse", IP: "198.51.100.2"}); err != nil || wait != 0 {
+		t.Fatalf("after unlock: wait %s, error %v", wait, err)
+	}
+	// Nothing left to unlock, nothing audited
+	if err := g.Unlock(ctx, user, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	if err := db.Model(&models.AuditLog{}).Where("user_id = ?", user.ID).Order("id").Pluck("event", &events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != EventAccountLocked || events[1] != EventAccountUnlocked {
+		t.Fatalf("audit events %v", events)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	g := &Guard{delayBase: time.Second, delayMax: 30 * time.Second}
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := g.delay(tt.failures); got != tt.delay {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.delay)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Package mfa manages TOTP two-factor authentication: enrollment, one-time
+// recovery codes and the short-lived challenges that complete a login.
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/pkg/secretbox"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/totp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAlreadyEnabled is returned when enrolling or confirming while
+	// two-factor authentication is already on
+	ErrAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	// ErrNotEnrolled is returned when there is no secret to check a code
+	// against
+	ErrNotEnrolled = errors.New("two-factor authentication not enrolled")
+	// ErrInvalidCode is returned for wrong, reused or expired codes
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// ErrInvalidChallenge is returned for unknown, used or expired login
+	// challenges and for challenges with too many wrong codes
+	ErrInvalidChallenge = errors.New("invalid mfa challenge")
+)
+
+// Audit log events written by the manager
+const (
+	EventEnabled          = "two_factor_enabled"
+	EventDisabled         = "two_factor_disabled"
+	EventRecoveryCodeUsed = "recovery_code_used"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+// Manager stores TOTP secrets sealed with the data encryption key
+type Manager struct {
+	db           *gorm.DB
+	box          *secretbox.Box
+	issuer       string
+	challengeTTL time.Duration
+	maxAttempts  int
+	now          func() time.Time
+}
+
+// NewManager builds a manager from the service config
+func NewManager(db *gorm.DB, cfg *config.Config) *Manager {
+	return &Manager{
+		db:           db,
+		box:          secretbox.New(cfg.DataEncryptionKey),
+		issuer:       cfg.TOTPIssuer,
+		challengeTTL: cfg.MFAChallengeTTL,
+		maxAttempts:  cfg.MFAChallengeAttempts,
+		now:          time.Now,
+	}
+}
+
+// ChallengeTTL is how long a login challenge can be redeemed
+func (m *Manager) ChallengeTTL() time.Duration {
+	return m.challengeTTL
+}
+
+// Enroll creates a new secret for user, replacing an unconfirmed one. It
+// takes effect once Confirm is called with a code generated from it.
+func (m *Manager) Enroll(ctx context.Context, user models.User) (models.EnrollTwoFactorResponse, error) {
+	if user.TwoFactorEnabled {
+		return models.EnrollTwoFactorResponse{}, ErrAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return models.EnrollTwoFactorResponse{}, err
+	}
+	sealed, err := m.box.Seal([]byte(secret))
+	if err != nil {
+		return models.EnrollTwoFactorResponse{}, err
+	}
+
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.TwoFactor{}, user.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TwoFactor{UserID: user.ID, Secret: sealed}).Error
+	})
+	if err != nil {
+		return models.EnrollTwoFactorResponse{}, err
+	}
+	return models.EnrollTwoFactorResponse{
+		Secret: secret,
+		URI:    totp.URI(m.issuer, user.Username, secret),
+	}, nil
+}
+
+// Confirm turns two-factor authentication on once code proves the user's
+// authenticator has the secret, and returns the first recovery codes
+func (m *Manager) Confirm(ctx context.Context, userID uint, code string) ([]string, error) {
+	var codes []string
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tf models.TwoFactor
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tf, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		if tf.ConfirmedAt != nil {
+			return ErrAlreadyEnabled
+		}
+		if err := m.checkTOTP(tx, &tf, code); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&tf).Update("confirmed_at", m.now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+		codes, err = m.replaceRecoveryCodes(tx, userID)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{Event: EventEnabled, UserID: &userID}).Error
+	})
+	return codes, err
+}
+
+// Verify checks a TOTP or recovery code of a user with two-factor
+// authentication on. Recovery codes are used up.
+func (m *Manager) Verify(ctx context.Context, userID uint, code string) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return m.verify(tx, userID, code)
+	})
+}
+
+// RecoveryCodes replaces the user's recovery codes after checking code
+func (m *Manager) RecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	var codes []string
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := m.verify(tx, userID, code); err != nil {
+			return err
+		}
+		var err error
+		codes, err = m.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable turns two-factor authentication off and removes the secret,
+// recovery codes and pending challenges. actorID is the user themselves or
+// the admin resetting it.
+func (m *Manager) Disable(ctx context.Context, userID, actorID uint) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{&models.RecoveryCode{}, &models.MFAChallenge{}} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&models.TwoFactor{}, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{Event: EventDisabled, UserID: &userID, ActorID: &actorID}).Error
+	})
+}
+
+// Challenge starts the second login step for a user whose password has been
+// checked. The returned token is redeemed with a code by Redeem.
+func (m *Manager) Challenge(ctx context.Context, userID uint) (string, error) {
+	token, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	now := m.now()
+	err = m.db.WithContext(ctx).Create(&models.MFAChallenge{
+		UserID:    userID,
+		TokenHash: randtoken.Hash(token),
+		ExpiresAt: now.Add(m.challengeTTL),
+		CreatedAt: now,
+	}).Error
+	return token, err
+}
+
+// Pending returns the user a challenge was issued to without using it up
+func (m *Manager) Pending(ctx context.Context, token string) (models.User, error) {
+	var user models.User
+	challenge, err := m.challenge(m.db.WithContext(ctx), token)
+	if err != nil {
+		return user, err
+	}
+	if err := m.db.WithContext(ctx).First(&user, challenge.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrInvalidChallenge
+		}
+		return user, err
+	}
+	return user, nil
+}
+
+// Redeem completes a login challenge with a TOTP or recovery code. A wrong
+// code counts against the challenge and returns ErrInvalidCode together
+// with the user.
+func (m *Manager) Redeem(ctx context.Context, token, code string) (models.User, error) {
+	var (
+		user  models.User
+		wrong bool
+	)
+
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		challenge, err := m.challenge(tx.Clauses(clause.Locking{Strength: "UPDATE"}), token)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, challenge.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidChallenge
+			}
+			return err
+		}
+
+		err = m.verify(tx, user.ID, code)
+		switch {
+		case errors.Is(err, ErrInvalidCode):
+			// The attempt must be counted, so it is committed and the
+			// error is reported after the transaction
+			wrong = true
+			return tx.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+		case errors.Is(err, ErrNotEnrolled):
+			// Turned off since the password was checked
+			return ErrInvalidChallenge
+		case err != nil:
+			return err
+		}
+		return tx.Model(&challenge).Update("used_at", m.now()).Error
+	})
+	if err == nil && wrong {
+		err = ErrInvalidCode
+	}
+	return user, err
+}
+
+// challenge finds a redeemable challenge by token
+func (m *Manager) challenge(tx *gorm.DB, token string) (models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", randtoken.Hash(token), m.now(), m.maxAttempts).
+		First(&challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return challenge, ErrInvalidChallenge
+	}
+	return challenge, err
+}
+
+// verify checks code inside tx; six digits are a TOTP code, anything else
+// a recovery code
+func (m *Manager) verify(tx *gorm.DB, userID uint, code string) error {
+	var tf models.TwoFactor
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		First(&tf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	code = normalize(code)
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		return m.checkTOTP(tx, &tf, code)
+	}
+
+	res := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, randtoken.Hash(code)).
+		Update("used_at", m.now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+	return tx.Create(&models.AuditLog{Event: EventRecoveryCodeUsed, UserID: &userID}).Error
+}
+
+// checkTOTP validates code against tf's secret and records its time step;
+// tf must be locked by the caller
+func (m *Manager) checkTOTP(tx *gorm.DB, tf *models.TwoFactor, code string) error {
+	secret, err := m.box.Open(tf.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(string(secret), code, m.now())
+	if !ok || step <= tf.LastStep {
+		return ErrInvalidCode
+	}
+	return tx.Model(tf).Update("last_step", step).Error
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and creates new
+// ones inside tx
+func (m *Manager) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		// Ten base32 characters, shown as xxxxx-xxxxx
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b)[:10])
+		codes[i] = code[:5] + "-" + code[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: randtoken.Hash(code)}
+	}
+	return codes, tx.Create(&rows).Error
+}
+
+// normalize makes codes comparable regardless of case, spaces and dashes
+func normalize(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
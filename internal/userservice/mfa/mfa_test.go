@@ -0,0 +1,186 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/totp"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// clock is a settable time source for managers under test
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+// enrolled returns a manager and a user with two-factor authentication on,
+// its secret and its recovery codes
+func enrolled(t *testing.T) (*Manager, *clock, models.User, string, []string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(db, &config.Config{
+		DataEncryptionKey:    "0123456789abcdef0123456789abcdef",
+		TOTPIssuer:           "Enchanted",
+		MFAChallengeTTL:      5 * time.Minute,
+		MFAChallengeAttempts: 3,
+	})
+	c := &clock{t: time.Unix(1700000000, 0)}
+	m.now = c.now
+
+	ctx := context.Background()
+	user := models.User{Username: "ayse", Email: "ayse@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := m.Enroll(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := m.Confirm(ctx, user.ID, code(t, enrollment.Secret, c.t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, c, user, enrollment.Secret, recovery
+}
+
+func code(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	m, c, user, secret, _ := enrolled(t)
+	ctx := context.Background()
+	confirmedAt := c.t
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		codeAt  time.Duration // relative to confirmation
+		err     error
+	}{
+		{name: "code used to confirm", err: ErrInvalidCode},
+		{name: "next step", advance: totp.Period, codeAt: totp.Period},
+		{name: "same code again", err: ErrInvalidCode, codeAt: totp.Period},
+		// Still within the skew, but older than the last step used
+		{name: "earlier step", advance: totp.Period, codeAt: 0, err: ErrInvalidCode},
+		{name: "skew ahead", codeAt: 3 * totp.Period},
+		{name: "step between", codeAt: 2 * totp.Period, err: ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		c.t = c.t.Add(tt.advance)
+		err := m.Verify(ctx, user.ID, code(t, secret, confirmedAt.Add(tt.codeAt)))
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestVerifyRecoveryCodes(t *testing.T) {
+	m, _, user, _, recovery := enrolled(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		code string
+		err  error
+	}{
+		{name: "as shown", code: recovery[0]},
+		{name: "used up", code: recovery[0], err: ErrInvalidCode},
+		{name: "upper case without dash", code: " " + strings.ToUpper(strings.ReplaceAll(recovery[1], "-", ""))},
+		{name: "unknown", code: "aaaaa-aaaaa", err: ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		if err := m.Verify(ctx, user.ID, tt.code); !errors.Is(err, tt.err) {
+			t.Fatalf("%s: %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// New codes replace the remaining old ones
+	fresh, err := m.RecoveryCodes(ctx, user.ID, recovery[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify(ctx, user.ID, recovery[3]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("replaced code: %v, want ErrInvalidCode", err)
+	}
+	if err := m.Verify(ctx, user.ID, fresh[0]); err != nil {
+		t.Fatalf("new code: %v", err)
+	}
+}
+
+func TestVerifyNotEnrolled(t *testing.T) {
+	m, _, user, _, recovery := enrolled(t)
+	ctx := context.Background()
+	if err := m.Disable(ctx, user.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify(ctx, user.ID, recovery[0]); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("after disable: %v, want ErrNotEnrolled", err)
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	tests := []struct {
+		name    string
+		wrong   int
+		advance time.Duration
+		err     error
+	}{
+		{name: "first try"},
+		{name: "after wrong codes", wrong: 2},
+		{name: "too many wrong codes", wrong: 3, err: ErrInvalidChallenge},
+		{name: "expired", advance: 5 * time.Minute, err: ErrInvalidChallenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c, user, _, recovery := enrolled(t)
+			ctx := context.Background()
+			token, err := m.Challenge(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range tt.wrong {
+				got, err := m.Redeem(ctx, token, "000000")
+				if !errors.Is(err, ErrInvalidCode) || got.ID != user.ID {
+					t.Fatalf("wrong code %d: user %d, %v", i, got.ID, err)
+				}
+			}
+			c.t = c.t.Add(tt.advance)
+
+			got, err := m.Redeem(ctx, token, recovery[0])
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Redeem: %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID != user.ID {
+				t.Fatalf("Redeem: user %d, want %d", got.ID, user.ID)
+			}
+			// A challenge is good for one login
+			if _, err := m.Redeem(ctx, token, recovery[1]); !errors.Is(err, ErrInvalidChallenge) {
+				t.Fatalf("second Redeem: %v, want ErrInvalidChallenge", err)
+			}
+		})
+	}
+}
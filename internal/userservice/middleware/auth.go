@@ -2,66 +2,71 @@ package middleware
 
 import (
 	"net/http"
-	"strings"
 
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/response"
 	"enchanted-micro/internal/userservice/config"
 	"enchanted-micro/internal/userservice/database"
 	"enchanted-micro/internal/userservice/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+func AuthMiddleware(cfg *config.Config, keys identity.Keys, revocations identity.RevocationChecker) gin.HandlerFunc {
+	// Gateway kimlik header'ları ya da Bearer token ile kimlik doğrulama,
+	// çıkış yapılmış token'lar reddedilir
+	authenticate := identity.Middleware(identity.Options{
+		Keys:           keys,
+		IdentitySecret: cfg.IdentitySecret,
+		Revocations:    revocations,
+	})
+
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header gerekli"})
-			c.Abort()
+		authenticate(c)
+		if c.IsAborted() {
 			return
 		}
+		id := identity.FromContext(c)
 
-		// "Bearer " prefix'ini kaldır
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token formatı"})
+		// User'ı veritabanından bul
+		var user models.User
+		if err := database.DB.WithContext(c.Request.Context()).First(&user, id.UserID).Error; err != nil {
+			response.Error(c, http.StatusUnauthorized, "Kullanıcı bulunamadı")
 			c.Abort()
 			return
 		}
 
-		// Token'ı parse et
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token"})
+		if user.Disabled() {
+			response.Error(c, http.StatusUnauthorized, "Hesap devre dışı")
 			c.Abort()
 			return
 		}
 
-		// Claims'den user ID'yi al
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz token claims"})
+		// Tüm cihazlardan çıkıştan önce verilmiş token'lar (diğer instance'ların
+		// cache'i henüz güncellenmemiş olsa bile) geçersizdir
+		if id.TokenVersion < user.TokenVersion {
+			response.Error(c, http.StatusUnauthorized, "Oturum sonlandırılmış")
 			c.Abort()
 			return
 		}
 
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Geçersiz user ID"})
-			c.Abort()
-			return
+		// Başka bir cihazdan sonlandırılmış oturumun token'ları da cache
+		// beklenmeden reddedilir
+		if id.SessionID != "" {
+			var session models.Session
+			err := database.DB.WithContext(c.Request.Context()).Select("id", "revoked_at").
+				Where("id = ? AND user_id = ?", id.SessionID, user.ID).First(&session).Error
+			if err != nil || session.RevokedAt != nil {
+				response.Error(c, http.StatusUnauthorized, "Oturum sonlandırılmış")
+				c.Abort()
+				return
+			}
 		}
 
-		// User'ı veritabanından bul
-		var user models.User
-		if err := database.DB.First(&user, uint(userID)).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Kullanıcı bulunamadı"})
-			c.Abort()
-			return
-		}
+		// Rol ve yetkiler token'dakiler yerine veritabanından güncel okunur;
+		// geri alınan yetkiler bu serviste hemen geçerli olur
+		id.Roles = user.Roles
+		id.Permissions = identity.Permissions(user.Roles, user.Permissions)
 
 		// User'ı context'e ekle
 		c.Set("user", user)
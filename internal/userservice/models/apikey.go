@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// APIKey - Kişisel API anahtarı. Anahtarın kendisi sadece oluşturulurken bir
+// kez gösterilir, SHA-256 hash'i saklanır; Prefix listede anahtarları ayırt
+// etmek içindir.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json;type:text;not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index;not null"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"` // Boşsa izin verilen en uzun süre
+}
+
+// CreateAPIKeyResponse - Key sadece bu cevapta döner
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
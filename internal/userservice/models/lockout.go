@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// LoginFailure - Bir kullanıcı adı ya da IP için başarısız giriş sayacı. Key
+// "user:<kullanıcı adı>" ya da "ip:<adres>" biçimindedir; kayıtlı olmayan
+// kullanıcı adları da sayılır. BlockedUntil'e kadar giriş denenemez,
+// ExpiresAt'ten sonra kayıt sıfırlanır.
+type LoginFailure struct {
+	Key          string     `json:"key" gorm:"primaryKey"`
+	Failures     int        `json:"failures" gorm:"not null;default:0"`
+	BlockedUntil time.Time  `json:"blocked_until" gorm:"not null"`
+	LockedAt     *time.Time `json:"locked_at,omitempty"` // Eşik aşıldıysa dolu
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"index;not null"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// AuditLog - Güvenlik olayı kaydı (hesap kilitlenmesi, kilidin açılması ...)
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Event     string    `json:"event" gorm:"index;not null"`
+	UserID    *uint     `json:"user_id,omitempty" gorm:"index"`  // Olayın ilgili olduğu kullanıcı
+	ActorID   *uint     `json:"actor_id,omitempty" gorm:"index"` // İşlemi yapan yönetici
+	Subject   string    `json:"subject"`                         // Kullanıcı adı ya da IP
+	IP        string    `json:"ip"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+type ListAuditLogsResponse struct {
+	Logs  []AuditLog `json:"logs"`
+	Total int64      `json:"total"`
+	Page  int        `json:"page"`
+	Limit int        `json:"limit"`
+}
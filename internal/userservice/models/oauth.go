@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// LinkedIdentity - Kullanıcıya bağlanmış harici (OIDC) hesap. Sağlayıcı ve
+// sağlayıcıdaki kullanıcı ID'si (sub) birlikte tekildir.
+type LinkedIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"-" gorm:"index;not null"`
+	Provider    string     `json:"provider" gorm:"uniqueIndex:idx_linked_identity_subject;not null"`
+	Subject     string     `json:"-" gorm:"uniqueIndex:idx_linked_identity_subject;not null"`
+	Email       string     `json:"email"` // Bağlanırken sağlayıcının bildirdiği adres
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OAuthState - Sağlayıcıya yönlendirilmiş, callback'i beklenen yetkilendirme.
+// State'in sadece hash'i saklanır; PKCE verifier ve nonce callback'te
+// kontrol edilir. LinkUserID doluysa hesap o kullanıcıya bağlanır.
+type OAuthState struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	StateHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Provider     string     `json:"provider" gorm:"not null"`
+	CodeVerifier string     `json:"-" gorm:"not null"`
+	Nonce        string     `json:"-" gorm:"not null"`
+	LinkUserID   *uint      `json:"link_user_id,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// OAuthLoginCode - Callback sonrası frontend'e verilen, token çiftiyle bir
+// kez değiştirilebilen kısa ömürlü kod. Token'lar böylece URL'de taşınmaz.
+type OAuthLoginCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OAuthLinkCode - Hesap bağlama callback'inden sonra frontend'e verilen kısa
+// ömürlü kod. Bağlama ancak akışı başlatan kullanıcı bu kodu kendi oturumuyla
+// gönderince yapılır; başkasının başlattığı bağlama linki böylece işe yaramaz.
+type OAuthLinkCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	Provider  string     `json:"provider" gorm:"not null"`
+	Subject   string     `json:"-" gorm:"not null"`
+	Email     string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OIDCProviderInfo - Giriş sayfasında gösterilecek sağlayıcı
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type OAuthTokenRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LinkIdentityRequest - Hesap bağlama callback'inin verdiği kod
+type LinkIdentityRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// OAuthLinkResponse - Hesap bağlamak için tarayıcının açacağı adres
+type OAuthLinkResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
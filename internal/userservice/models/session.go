@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Session - Bir girişle başlayan oturum. ID refresh token ailesinin ID'sidir
+// ve access token'larda sid claim'i olarak taşınır. Cihaz bilgisi ve son
+// görülme zamanı her token yenilemede güncellenir.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"index;not null"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index;not null"`
+	RevokedAt  *time.Time `json:"-" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Current - İsteği yapan token'ın oturumu
+	Current bool `json:"current" gorm:"-"`
+}
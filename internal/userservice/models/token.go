@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// RefreshToken - Refresh token kaydı. Token'ın kendisi değil SHA-256 hash'i
+// saklanır. Aynı girişten türeyen token'lar aynı FamilyID'yi paylaşır;
+// kullanılmış bir token tekrar gelirse tüm aile iptal edilir.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	FamilyID  string     `json:"family_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	UserAgent string     `json:"user_agent"`
+	IP        string     `json:"ip"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken - Çıkış yapılmış access token (jti). Token'ın kendi süresi
+// dolana kadar tutulur.
+type RevokedToken struct {
+	TokenID   string    `json:"token_id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// SigningKey - Access token imzalama anahtarı. Private key PKCS#8 PEM olarak,
+// DATA_ENCRYPTION_KEY tanımlıysa şifrelenmiş saklanır. ActivatesAt'ten itibaren
+// imzalamada kullanılır, ExpiresAt'e kadar JWKS'te yayınlanır.
+type SigningKey struct {
+	KID         string     `json:"kid" gorm:"column:kid;primaryKey"`
+	Algorithm   string     `json:"algorithm" gorm:"not null"`
+	PrivateKey  string     `json:"-" gorm:"not null"`
+	ActivatesAt time.Time  `json:"activates_at" gorm:"index;not null"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse - Giriş ve yenileme cevabı
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// TwoFactor - Kullanıcının TOTP anahtarı. Secret DATA_ENCRYPTION_KEY
+// tanımlıysa şifrelenmiş saklanır. ConfirmedAt boşsa kurulum henüz bir kodla
+// onaylanmamıştır.
+type TwoFactor struct {
+	UserID      uint       `json:"user_id" gorm:"primaryKey"`
+	Secret      string     `json:"-" gorm:"not null"`
+	LastStep    int64      `json:"-" gorm:"not null;default:0"` // Son kullanılan kodun zaman adımı, tekrar kullanımı engeller
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode - Telefona erişilemediğinde TOTP kodu yerine kullanılan tek
+// kullanımlık kod. Sadece hash'i saklanır.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MFAChallenge - Şifresi doğrulanmış ama ikinci adımı bekleyen giriş.
+// Sadece token'ın hash'i saklanır; kısa ömürlüdür ve sınırlı sayıda kod
+// denemesine izin verir.
+type MFAChallenge struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// EnrollTwoFactorResponse - Authenticator uygulamasına eklenecek anahtar
+type EnrollTwoFactorResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"` // Şifresi olmayan (OIDC) hesaplarda boş
+	Code     string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse - Kurtarma kodları sadece oluşturulduklarında bir
+// kez gösterilir
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse - İki adımlı doğrulama açık hesaplarda Login cevabı
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP ya da kurtarma kodu
+}
@@ -7,13 +7,87 @@ import (
 )
 
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"` // JSON'da şifre gösterilmez
-	Email     string         `json:"email" gorm:"uniqueIndex"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                  uint              `json:"id" gorm:"primaryKey"`
+	Username            string            `json:"username" gorm:"uniqueIndex;not null"`
+	Password            string            `json:"-" gorm:"not null"` // JSON'da şifre gösterilmez
+	Email               string            `json:"email" gorm:"uniqueIndex"`
+	DisplayName         string            `json:"display_name"` // Boşsa kullanıcı adı gösterilir
+	Bio                 string            `json:"bio" gorm:"type:text"`
+	AvatarURL           string            `json:"avatar_url"`                                             // Yüklenen avatarın varsayılan boyutu
+	AvatarURLs          map[string]string `json:"avatar_urls,omitempty" gorm:"serializer:json;type:text"` // Boyut adına göre (small, medium, large)
+	Location            string            `json:"location"`
+	EmailVerifiedAt     *time.Time        `json:"email_verified_at,omitempty"` // Doğrulanmamışsa nil
+	Roles               []string          `json:"roles" gorm:"serializer:json;type:text;not null;default:'[\"user\"]'"`
+	Permissions         []string          `json:"permissions,omitempty" gorm:"serializer:json;type:text"` // Rollere ek olarak verilen yetkiler
+	DisabledAt          *time.Time        `json:"disabled_at,omitempty"`                                  // Yönetici tarafından devre dışı bırakıldıysa dolu
+	TwoFactorEnabled    bool              `json:"two_factor_enabled" gorm:"not null;default:false"`       // Girişte TOTP ya da kurtarma kodu istenir
+	DeletionScheduledAt *time.Time        `json:"deletion_scheduled_at,omitempty" gorm:"index"`           // Kullanıcı hesabını sildiyse silinme zamanı; o zamana kadar geri alınabilir
+	DeletingAt          *time.Time        `json:"-"`                                                      // Silme başladıysa dolu; hesap artık geri alınamaz
+	TokenVersion        uint              `json:"-" gorm:"not null;default:0"`                            // Artınca eski token'lar geçersiz olur
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// EmailVerified - E-posta adresi doğrulanmış mı
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Disabled - Hesap yönetici tarafından devre dışı bırakılmış mı
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// DeletionScheduled - Hesap silinmek üzere işaretlenmiş mi
+func (u User) DeletionScheduled() bool {
+	return u.DeletionScheduledAt != nil
+}
+
+// Public - Herkese açık profil; e-posta, roller ve hesap durumu içermez
+func (u User) Public() PublicProfile {
+	displayName := u.DisplayName
+	if displayName == "" {
+		displayName = u.Username
+	}
+	return PublicProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: displayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		AvatarURLs:  u.AvatarURLs,
+		Location:    u.Location,
+		JoinedAt:    u.CreatedAt,
+	}
+}
+
+// PublicProfile - Satıcı bilgisi olarak diğer kullanıcılara gösterilen profil
+type PublicProfile struct {
+	ID          uint              `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	Bio         string            `json:"bio"`
+	AvatarURL   string            `json:"avatar_url"`
+	AvatarURLs  map[string]string `json:"avatar_urls,omitempty"`
+	Location    string            `json:"location"`
+	JoinedAt    time.Time         `json:"joined_at"`
+}
+
+// UpdateProfileRequest - Gönderilmeyen alanlar değişmez; boş metin alanı
+// temizler. Avatar ayrıca yüklenir.
+type UpdateProfileRequest struct {
+	Email       string  `json:"email" binding:"omitempty,email"`
+	DisplayName *string `json:"display_name" binding:"omitempty,max=50"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	Location    *string `json:"location" binding:"omitempty,max=100"`
+}
+
+// DeleteAccountRequest - Şifresi olan hesaplarda şifre, iki adımlı doğrulama
+// açıksa kod da istenir
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
 }
 
 type CreateUserRequest struct {
@@ -23,11 +97,23 @@ type CreateUserRequest struct {
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
+	Username string `json:"username" binding:"required,max=100"`
 	Password string `json:"password" binding:"required"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	TokenResponse
+	User User `json:"user"`
+}
+
+type UpdateRolesRequest struct {
+	Roles       []string `json:"roles" binding:"required,min=1,dive,required"`
+	Permissions []string `json:"permissions" binding:"dive,required"`
+}
+
+type ListUsersResponse struct {
+	Users []User `json:"users"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// EmailVerificationToken - E-posta doğrulama token'ı. Sadece hash'i saklanır.
+// Gönderildiği adres de tutulur; kullanıcı e-postasını değiştirirse eski
+// adrese giden token'lar geçersiz olur.
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	Email     string     `json:"email" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,67 @@
+// Package notify tells users about account events. The email notifier
+// renders the messages and hands them to a mail.Sender; other channels can
+// implement Notifier without touching the handlers.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"enchanted-micro/internal/userservice/mail"
+	"enchanted-micro/internal/userservice/models"
+)
+
+// Notifier delivers account notifications carrying a single-use token
+type Notifier interface {
+	PasswordReset(ctx context.Context, user models.User, token string) error
+	EmailVerification(ctx context.Context, user models.User, token string) error
+}
+
+// Email sends notifications as emails with links into the frontend at appURL
+type Email struct {
+	sender    mail.Sender
+	appURL    string
+	resetTTL  time.Duration
+	verifyTTL time.Duration
+}
+
+// NewEmail builds an email notifier
+func NewEmail(sender mail.Sender, appURL string, resetTTL, verifyTTL time.Duration) *Email {
+	return &Email{sender: sender, appURL: appURL, resetTTL: resetTTL, verifyTTL: verifyTTL}
+}
+
+func (e *Email) PasswordReset(ctx context.Context, user models.User, token string) error {
+	return e.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Şifre sıfırlama",
+		Body: fmt.Sprintf("Merhaba %s,\n\n"+
+			"Şifreni sıfırlamak için aşağıdaki bağlantıyı kullan:\n\n%s\n\n"+
+			"Bağlantı %s boyunca ve yalnızca bir kez geçerlidir. Bu isteği sen yapmadıysan bu e-postayı yok sayabilirsin.\n",
+			user.Username, e.link("/reset-password", token), duration(e.resetTTL)),
+	})
+}
+
+func (e *Email) EmailVerification(ctx context.Context, user models.User, token string) error {
+	return e.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "E-posta adresini doğrula",
+		Body: fmt.Sprintf("Merhaba %s,\n\n"+
+			"E-posta adresini doğrulamak için aşağıdaki bağlantıyı kullan:\n\n%s\n\n"+
+			"Bağlantı %s boyunca geçerlidir. Bu hesabı sen oluşturmadıysan bu e-postayı yok sayabilirsin.\n",
+			user.Username, e.link("/verify-email", token), duration(e.verifyTTL)),
+	})
+}
+
+func (e *Email) link(path, token string) string {
+	return e.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// duration formats d in Turkish, in whole hours when possible
+func duration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d saat", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d dakika", int(d.Minutes()))
+}
@@ -0,0 +1,156 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/userservice/config"
+	umail "enchanted-micro/internal/userservice/mail"
+	"enchanted-micro/internal/userservice/models"
+	"enchanted-micro/internal/userservice/notify"
+	"enchanted-micro/internal/userservice/tokens"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setup returns an issuer on an in-memory database, an email notifier
+// writing to a temporary directory through the file driver, and that
+// directory
+func setup(t *testing.T) (*tokens.Issuer, *notify.Email, string, models.User) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.Session{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "ayse", Email: "ayse@example.com", Password: "old"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		MailDriver:              "file",
+		MailDir:                 t.TempDir(),
+		MailFrom:                "Enchanted <no-reply@enchanted.local>",
+		AppURL:                  "http://localhost:3000",
+		PasswordResetTTL:        time.Hour,
+		EmailVerificationTTL:    24 * time.Hour,
+		EmailVerificationResend: time.Minute,
+	}
+	sender, err := umail.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := notify.NewEmail(sender, cfg.AppURL, cfg.PasswordResetTTL, cfg.EmailVerificationTTL)
+	return tokens.NewIssuer(db, cfg, nil), email, cfg.MailDir, user
+}
+
+// readMail parses the only message in dir and returns the token of the
+// link to path in its body
+func readMail(t *testing.T, dir, path string) (*mail.Message, string) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mail files %v, %v", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "http://localhost:3000" + path + "?"
+	for _, line := range strings.Split(string(body), "\r\n") {
+		if rest, ok := strings.CutPrefix(line, prefix); ok {
+			query, err := url.ParseQuery(rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return msg, query.Get("token")
+		}
+	}
+	t.Fatalf("no %s link in body:\n%s", path, body)
+	return nil, ""
+}
+
+func checkHeaders(t *testing.T, msg *mail.Message, subject string) {
+	t.Helper()
+	if got := msg.Header.Get("To"); got != "ayse@example.com" {
+		t.Errorf("To %q", got)
+	}
+	if got := msg.Header.Get("From"); got != "Enchanted <no-reply@enchanted.local>" {
+		t.Errorf("From %q", got)
+	}
+	got, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || got != subject {
+		t.Errorf("Subject %q, %v; want %q", got, err, subject)
+	}
+}
+
+func TestPasswordResetMail(t *testing.T) {
+	ctx := context.Background()
+	issuer, email, dir, user := setup(t)
+
+	token, err := issuer.PasswordReset(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := email.PasswordReset(ctx, user, token); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, got := readMail(t, dir, "/reset-password")
+	checkHeaders(t, msg, "Şifre sıfırlama")
+	if got != token {
+		t.Fatalf("mailed token %q, want %q", got, token)
+	}
+	// The mailed link resets the password once
+	if _, err := issuer.ResetPassword(ctx, got, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.ResetPassword(ctx, got, "newer"); err != tokens.ErrInvalidResetToken {
+		t.Fatalf("second reset: %v", err)
+	}
+}
+
+func TestEmailVerificationMail(t *testing.T) {
+	ctx := context.Background()
+	issuer, email, dir, user := setup(t)
+
+	token, err := issuer.EmailVerification(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := email.EmailVerification(ctx, user, token); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, got := readMail(t, dir, "/verify-email")
+	checkHeaders(t, msg, "E-posta adresini doğrula")
+	verified, err := issuer.VerifyEmail(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.EmailVerified() {
+		t.Fatal("email not verified")
+	}
+}
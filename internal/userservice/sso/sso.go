@@ -0,0 +1,550 @@
+// Package sso signs users in with external OpenID Connect providers. It runs
+// the authorization code flow with PKCE, links provider accounts to local
+// users and creates an account on the first sign in.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnknownProvider is returned for providers that are not configured
+	ErrUnknownProvider = errors.New("unknown oidc provider")
+	// ErrInvalidState is returned for unknown, used or expired states
+	ErrInvalidState = errors.New("invalid oauth state")
+	// ErrInvalidLoginCode is returned for unknown, used or expired login
+	// codes
+	ErrInvalidLoginCode = errors.New("invalid oauth login code")
+	// ErrInvalidLinkCode is returned for unknown, used or expired link
+	// codes and for codes of a link another user started
+	ErrInvalidLinkCode = errors.New("invalid oauth link code")
+	// ErrEmailRequired is returned when a new account would be created but
+	// the provider did not share an email address
+	ErrEmailRequired = errors.New("oidc provider returned no email")
+	// ErrAccountExists is returned when a new account would be created with
+	// the email of an existing one. Accounts are never linked by email; the
+	// owner has to sign in and link the provider.
+	ErrAccountExists = errors.New("email belongs to an existing account")
+	// ErrIdentityInUse is returned when linking a provider account that is
+	// linked to another user
+	ErrIdentityInUse = errors.New("identity linked to another user")
+	// ErrIdentityNotFound is returned when unlinking an identity the user
+	// does not have
+	ErrIdentityNotFound = errors.New("linked identity not found")
+	// ErrLastLoginMethod is returned when unlinking the only identity of a
+	// user without a password
+	ErrLastLoginMethod = errors.New("cannot unlink the only way to sign in")
+)
+
+// Audit log events written by the manager
+const (
+	EventIdentityLinked   = "identity_linked"
+	EventIdentityUnlinked = "identity_unlinked"
+)
+
+const (
+	// stateTTL is how long the user has to sign in at the provider
+	stateTTL = 10 * time.Minute
+	// codeTTL is how long the frontend has to exchange a login or link code
+	codeTTL = time.Minute
+	// usernameAttempts is how many suffixed usernames are tried before
+	// giving up on a taken one
+	usernameAttempts = 10
+	// Username length limits of CreateUserRequest
+	minUsername = 3
+	maxUsername = 20
+)
+
+// Manager runs sign ins for the configured providers
+type Manager struct {
+	db        *gorm.DB
+	providers map[string]*provider
+	names     []string // config order
+	baseURL   string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// provider discovers its endpoints on first use, so the service starts even
+// when a provider is unreachable
+type provider struct {
+	config config.OIDCProvider
+	mu     sync.Mutex
+	oidc   *oidc.Provider
+}
+
+// Result is the outcome of a successful callback
+type Result struct {
+	User models.User
+	// Created is set when the account was created by this sign in
+	Created bool
+	// LinkCode is set instead of User when the state was started to link
+	// a provider. The signed in user completes the link with it in Link,
+	// so a link started by someone else cannot attach their account.
+	LinkCode string
+}
+
+// claims are the ID token claims accounts are created from
+type claims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Nickname          string `json:"nickname"`
+	Name              string `json:"name"`
+}
+
+// NewManager builds a manager from the service config
+func NewManager(db *gorm.DB, cfg *config.Config) *Manager {
+	m := &Manager{
+		db:        db,
+		providers: make(map[string]*provider),
+		baseURL:   strings.TrimSuffix(cfg.OAuthBaseURL, "/"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+	}
+	for _, p := range cfg.OIDCProviders {
+		m.providers[p.Name] = &provider{config: p}
+		m.names = append(m.names, p.Name)
+	}
+	return m
+}
+
+// Providers lists the configured providers
+func (m *Manager) Providers() []models.OIDCProviderInfo {
+	providers := make([]models.OIDCProviderInfo, 0, len(m.names))
+	for _, name := range m.names {
+		p := m.providers[name].config
+		providers = append(providers, models.OIDCProviderInfo{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	return providers
+}
+
+// StateTTL is how long a state from Begin can be used
+func (m *Manager) StateTTL() time.Duration {
+	return stateTTL
+}
+
+// Begin starts an authorization with providerName and returns its state.
+// A non-zero linkUserID makes the callback issue a code for linking the
+// provider account to that user instead of signing in.
+func (m *Manager) Begin(ctx context.Context, providerName string, linkUserID uint) (string, error) {
+	if _, ok := m.providers[providerName]; !ok {
+		return "", ErrUnknownProvider
+	}
+	state, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+
+	now := m.now()
+	row := models.OAuthState{
+		StateHash:    randtoken.Hash(state),
+		Provider:     providerName,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresAt:    now.Add(stateTTL),
+		CreatedAt:    now,
+	}
+	if linkUserID != 0 {
+		row.LinkUserID = &linkUserID
+	}
+	return state, m.db.WithContext(ctx).Create(&row).Error
+}
+
+// AuthorizeURL is the service's own authorize endpoint for state; the link
+// flow hands it to the browser, which has to visit it to bind the state
+func (m *Manager) AuthorizeURL(providerName, state string) string {
+	return m.baseURL + "/" + url.PathEscape(providerName) + "/authorize?state=" + url.QueryEscape(state)
+}
+
+// AuthorizationURL returns where to send the browser to sign in at the
+// provider for a state from Begin
+func (m *Manager) AuthorizationURL(ctx context.Context, providerName, state string) (string, error) {
+	p, ok := m.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	row, err := m.state(m.db.WithContext(ctx), providerName, state)
+	if err != nil {
+		return "", err
+	}
+	oauthConfig, _, err := m.discover(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(row.CodeVerifier), oidc.Nonce(row.Nonce)), nil
+}
+
+// Callback completes the authorization for state with the code the
+// provider redirected back with. The state is used up even when the
+// exchange fails.
+func (m *Manager) Callback(ctx context.Context, providerName, state, code string) (Result, error) {
+	p, ok := m.providers[providerName]
+	if !ok {
+		return Result{}, ErrUnknownProvider
+	}
+
+	var row models.OAuthState
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		row, err = m.state(tx.Clauses(clause.Locking{Strength: "UPDATE"}), providerName, state)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&row).Update("used_at", m.now()).Error
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	oauthConfig, op, err := m.discover(ctx, p)
+	if err != nil {
+		return Result{}, err
+	}
+	ctx = oidc.ClientContext(ctx, m.client)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(row.CodeVerifier))
+	if err != nil {
+		return Result{}, fmt.Errorf("code exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Result{}, errors.New("token response without id_token")
+	}
+	idToken, err := op.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return Result{}, fmt.Errorf("id token: %w", err)
+	}
+	if idToken.Nonce != row.Nonce {
+		return Result{}, errors.New("id token nonce mismatch")
+	}
+	var c claims
+	if err := idToken.Claims(&c); err != nil {
+		return Result{}, fmt.Errorf("id token claims: %w", err)
+	}
+
+	if row.LinkUserID != nil {
+		code, err := m.issueLinkCode(ctx, *row.LinkUserID, providerName, idToken.Subject, c)
+		return Result{LinkCode: code}, err
+	}
+	return m.signIn(ctx, providerName, idToken.Subject, c)
+}
+
+// IssueLoginCode returns a one-time code the frontend exchanges for tokens
+// with RedeemLoginCode
+func (m *Manager) IssueLoginCode(ctx context.Context, userID uint) (string, error) {
+	code, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	now := m.now()
+	err = m.db.WithContext(ctx).Create(&models.OAuthLoginCode{
+		UserID:    userID,
+		CodeHash:  randtoken.Hash(code),
+		ExpiresAt: now.Add(codeTTL),
+		CreatedAt: now,
+	}).Error
+	return code, err
+}
+
+// RedeemLoginCode uses up a login code and returns the user it was issued to
+func (m *Manager) RedeemLoginCode(ctx context.Context, code string) (models.User, error) {
+	var user models.User
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.OAuthLoginCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ? AND used_at IS NULL AND expires_at > ?", randtoken.Hash(code), m.now()).
+			First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidLoginCode
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Update("used_at", m.now()).Error; err != nil {
+			return err
+		}
+		err = tx.First(&user, row.UserID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidLoginCode
+		}
+		return err
+	})
+	return user, err
+}
+
+// Identities lists the provider accounts linked to the user
+func (m *Manager) Identities(ctx context.Context, userID uint) ([]models.LinkedIdentity, error) {
+	identities := []models.LinkedIdentity{}
+	err := m.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// Unlink removes a linked identity. Users without a password must keep at
+// least one.
+func (m *Manager) Unlink(ctx context.Context, userID, identityID uint) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "username", "password").
+			First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var linked models.LinkedIdentity
+		err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&linked).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if user.Password == "" {
+			var count int64
+			if err := tx.Model(&models.LinkedIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count <= 1 {
+				return ErrLastLoginMethod
+			}
+		}
+
+		if err := tx.Delete(&linked).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{Event: EventIdentityUnlinked, UserID: &userID, ActorID: &userID, Subject: user.Username, Detail: linked.Provider}).Error
+	})
+}
+
+// signIn returns the user linked to the provider account, creating one on
+// the first sign in
+func (m *Manager) signIn(ctx context.Context, providerName, subject string, c claims) (Result, error) {
+	var result Result
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var linked models.LinkedIdentity
+		err := tx.Where("provider = ? AND subject = ?", providerName, subject).First(&linked).Error
+		if err == nil {
+			if err := tx.Model(&linked).Update("last_login_at", m.now()).Error; err != nil {
+				return err
+			}
+			return tx.First(&result.User, linked.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		user, err := m.createUser(tx, c)
+		if err != nil {
+			return err
+		}
+		now := m.now()
+		linked = models.LinkedIdentity{UserID: user.ID, Provider: providerName, Subject: subject, Email: c.Email, LastLoginAt: &now}
+		if err := tx.Create(&linked).Error; err != nil {
+			return err
+		}
+		result = Result{User: user, Created: true}
+		return tx.Create(&models.AuditLog{Event: EventIdentityLinked, UserID: &user.ID, Subject: user.Username, Detail: providerName + ", account created"}).Error
+	})
+	return result, err
+}
+
+// issueLinkCode returns a one-time code for linking the provider account
+// to userID with Link
+func (m *Manager) issueLinkCode(ctx context.Context, userID uint, providerName, subject string, c claims) (string, error) {
+	code, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	now := m.now()
+	err = m.db.WithContext(ctx).Create(&models.OAuthLinkCode{
+		UserID:    userID,
+		CodeHash:  randtoken.Hash(code),
+		Provider:  providerName,
+		Subject:   subject,
+		Email:     c.Email,
+		ExpiresAt: now.Add(codeTTL),
+		CreatedAt: now,
+	}).Error
+	return code, err
+}
+
+// Link uses up a link code and attaches its provider account to userID,
+// which must be the user who started the link. A code presented by anyone
+// else is used up as well.
+func (m *Manager) Link(ctx context.Context, userID uint, code string) (models.LinkedIdentity, error) {
+	var row models.OAuthLinkCode
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ? AND used_at IS NULL AND expires_at > ?", randtoken.Hash(code), m.now()).
+			First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidLinkCode
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&row).Update("used_at", m.now()).Error
+	})
+	if err != nil {
+		return models.LinkedIdentity{}, err
+	}
+	if row.UserID != userID {
+		return models.LinkedIdentity{}, ErrInvalidLinkCode
+	}
+
+	var linked models.LinkedIdentity
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "username").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("provider = ? AND subject = ?", row.Provider, row.Subject).First(&linked).Error
+		if err == nil {
+			if linked.UserID != userID {
+				return ErrIdentityInUse
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		linked = models.LinkedIdentity{UserID: userID, Provider: row.Provider, Subject: row.Subject, Email: row.Email}
+		if err := tx.Create(&linked).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuditLog{Event: EventIdentityLinked, UserID: &userID, ActorID: &userID, Subject: user.Username, Detail: row.Provider}).Error
+	})
+	return linked, err
+}
+
+// createUser creates an account without a password from the provider's
+// claims, picking a free username
+func (m *Manager) createUser(tx *gorm.DB, c claims) (models.User, error) {
+	if c.Email == "" {
+		return models.User{}, ErrEmailRequired
+	}
+	var taken int64
+	if err := tx.Unscoped().Model(&models.User{}).Where("email = ?", c.Email).Count(&taken).Error; err != nil {
+		return models.User{}, err
+	}
+	if taken > 0 {
+		return models.User{}, ErrAccountExists
+	}
+
+	username, err := freeUsername(tx, baseUsername(c))
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		Username: username,
+		Email:    c.Email,
+		Roles:    []string{identity.RoleUser},
+	}
+	if c.EmailVerified {
+		now := m.now()
+		user.EmailVerifiedAt = &now
+	}
+	return user, tx.Create(&user).Error
+}
+
+// baseUsername derives a username from the claims: letters, digits, '_' and
+// '.' of the preferred username, nickname, email local part or name
+func baseUsername(c claims) string {
+	local, _, _ := strings.Cut(c.Email, "@")
+	for _, candidate := range []string{c.PreferredUsername, c.Nickname, local, c.Name} {
+		var b strings.Builder
+		for _, r := range strings.ToLower(candidate) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' {
+				b.WriteRune(r)
+			}
+		}
+		// Leave room for a numeric suffix
+		name := []rune(b.String())
+		if len(name) > maxUsername-4 {
+			name = name[:maxUsername-4]
+		}
+		if len(name) >= minUsername {
+			return string(name)
+		}
+	}
+	return "user"
+}
+
+// freeUsername returns base, or base with a random four digit suffix when
+// it is taken
+func freeUsername(tx *gorm.DB, base string) (string, error) {
+	candidate := base
+	for range usernameAttempts {
+		var taken int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%04d", base, n.Int64())
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
+
+// state finds an unused, unexpired state of providerName
+func (m *Manager) state(tx *gorm.DB, providerName, state string) (models.OAuthState, error) {
+	var row models.OAuthState
+	err := tx.Where("state_hash = ? AND provider = ? AND used_at IS NULL AND expires_at > ?", randtoken.Hash(state), providerName, m.now()).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return row, ErrInvalidState
+	}
+	return row, err
+}
+
+// discover returns the OAuth2 config and OIDC provider of p, fetching the
+// discovery document on first use
+func (m *Manager) discover(ctx context.Context, p *provider) (*oauth2.Config, *oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oidc == nil {
+		op, err := oidc.NewProvider(oidc.ClientContext(ctx, m.client), p.config.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery for %s: %w", p.config.Name, err)
+		}
+		p.oidc = op
+	}
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     p.oidc.Endpoint(),
+		RedirectURL:  m.baseURL + "/" + url.PathEscape(p.config.Name) + "/callback",
+		Scopes:       p.config.Scopes,
+	}, p.oidc, nil
+}
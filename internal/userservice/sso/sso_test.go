@@ -0,0 +1,380 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// mockProvider is an OpenID Connect provider serving discovery, JWKS and a
+// token endpoint that checks PKCE. The authorization step is simulated by
+// authorize, which stands in for the user signing in at the provider.
+type mockProvider struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+	// nonce replaces the nonce of the authorization request in ID tokens
+	nonce string
+}
+
+type grant struct {
+	challenge string
+	nonce     string
+	subject   string
+	email     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key, grants: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                p.srv.URL,
+			"authorization_endpoint":                p.srv.URL + "/authorize",
+			"token_endpoint":                        p.srv.URL + "/token",
+			"jwks_uri":                              p.srv.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+// authorize checks the authorization request the manager built and returns
+// the code the provider redirects back with after subject signed in
+func (p *mockProvider) authorize(t *testing.T, authURL, subject, email string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("response_type") != "code" || q.Get("client_id") != "enchanted" {
+		t.Fatalf("authorization request %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without PKCE: %s", authURL)
+	}
+	if q.Get("nonce") == "" || q.Get("state") == "" {
+		t.Fatalf("authorization request without nonce or state: %s", authURL)
+	}
+
+	code, err := randtoken.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.grants[code] = grant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), subject: subject, email: email}
+	p.mu.Unlock()
+	return code
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	p.mu.Lock()
+	g, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	nonce := p.nonce
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if nonce == "" {
+		nonce = g.nonce
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.srv.URL,
+		"sub":                g.subject,
+		"aud":                "enchanted",
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              g.email,
+		"email_verified":     true,
+		"preferred_username": g.subject,
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newTestManager(t *testing.T, p *mockProvider) *Manager {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.LinkedIdentity{}, &models.OAuthState{}, &models.OAuthLoginCode{}, &models.OAuthLinkCode{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(db, &config.Config{
+		OAuthBaseURL: "http://localhost:8090/user/oauth",
+		OIDCProviders: []config.OIDCProvider{{
+			Name:         "mock",
+			Issuer:       p.srv.URL,
+			ClientID:     "enchanted",
+			ClientSecret: "secret",
+			Scopes:       []string{"openid", "email", "profile"},
+		}},
+	})
+}
+
+// signIn runs a whole authorization for subject and returns its result
+func signIn(t *testing.T, m *Manager, p *mockProvider, linkUserID uint, subject, email string) (Result, error) {
+	t.Helper()
+	ctx := context.Background()
+	state, err := m.Begin(ctx, "mock", linkUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := m.AuthorizationURL(ctx, "mock", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := p.authorize(t, authURL, subject, email)
+	return m.Callback(ctx, "mock", state, code)
+}
+
+func TestSignIn(t *testing.T) {
+	p := newMockProvider(t)
+	m := newTestManager(t, p)
+
+	first, err := signIn(t, m, p, 0, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Created || first.User.Email != "alice@example.com" || !first.User.EmailVerified() {
+		t.Fatalf("first sign in %+v", first)
+	}
+
+	again, err := signIn(t, m, p, 0, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created || again.User.ID != first.User.ID {
+		t.Fatalf("second sign in %+v, want user %d", again, first.User.ID)
+	}
+
+	// Another provider account with the same email is not linked by email
+	if _, err := signIn(t, m, p, 0, "mallory", "alice@example.com"); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("same email: %v, want ErrAccountExists", err)
+	}
+}
+
+func TestCallbackState(t *testing.T) {
+	ctx := context.Background()
+	p := newMockProvider(t)
+	m := newTestManager(t, p)
+
+	state, err := m.Begin(ctx, "mock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := m.AuthorizationURL(ctx, "mock", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := p.authorize(t, authURL, "alice", "alice@example.com")
+
+	if _, err := m.Callback(ctx, "mock", "forged", code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("unknown state: %v, want ErrInvalidState", err)
+	}
+	if _, err := m.Callback(ctx, "other", state, code); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("other provider: %v, want ErrUnknownProvider", err)
+	}
+	if _, err := m.Callback(ctx, "mock", state, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Callback(ctx, "mock", state, code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("reused state: %v, want ErrInvalidState", err)
+	}
+
+	expired, err := m.Begin(ctx, "mock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Now().Add(stateTTL + time.Second) }
+	if _, err := m.Callback(ctx, "mock", expired, code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expired state: %v, want ErrInvalidState", err)
+	}
+}
+
+func TestCallbackPKCE(t *testing.T) {
+	ctx := context.Background()
+	p := newMockProvider(t)
+	m := newTestManager(t, p)
+
+	// A code obtained for another authorization carries another challenge,
+	// so the verifier of this state does not redeem it
+	other, err := m.Begin(ctx, "mock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherURL, err := m.AuthorizationURL(ctx, "mock", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stolen := p.authorize(t, otherURL, "alice", "alice@example.com")
+
+	state, err := m.Begin(ctx, "mock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Callback(ctx, "mock", state, stolen); err == nil || !strings.Contains(err.Error(), "code exchange") {
+		t.Fatalf("code redeemed with another state's verifier: %v", err)
+	}
+}
+
+func TestCallbackNonce(t *testing.T) {
+	p := newMockProvider(t)
+	m := newTestManager(t, p)
+
+	p.nonce = "replayed"
+	if _, err := signIn(t, m, p, 0, "alice", "alice@example.com"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("ID token with another nonce accepted: %v", err)
+	}
+	var users int64
+	m.db.Model(&models.User{}).Count(&users)
+	if users != 0 {
+		t.Fatalf("%d users created", users)
+	}
+}
+
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+	p := newMockProvider(t)
+	m := newTestManager(t, p)
+
+	owner := models.User{Username: "owner", Email: "owner@example.com", Password: "hash"}
+	victim := models.User{Username: "victim", Email: "victim@example.com", Password: "hash"}
+	for _, u := range []*models.User{&owner, &victim} {
+		if err := m.db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The owner starts a link but the victim signs in at the provider and
+	// presents the code: nothing is linked and the code is used up
+	result, err := signIn(t, m, p, owner.ID, "victim-sub", "victim@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.LinkCode == "" || result.User.ID != 0 {
+		t.Fatalf("link callback %+v", result)
+	}
+	if _, err := m.Link(ctx, victim.ID, result.LinkCode); !errors.Is(err, ErrInvalidLinkCode) {
+		t.Fatalf("other user's code: %v, want ErrInvalidLinkCode", err)
+	}
+	if _, err := m.Link(ctx, owner.ID, result.LinkCode); !errors.Is(err, ErrInvalidLinkCode) {
+		t.Fatalf("used code: %v, want ErrInvalidLinkCode", err)
+	}
+	var linkedCount int64
+	m.db.Model(&models.LinkedIdentity{}).Count(&linkedCount)
+	if linkedCount != 0 {
+		t.Fatalf("%d identities linked", linkedCount)
+	}
+
+	// The owner completes their own link
+	result, err = signIn(t, m, p, owner.ID, "owner-sub", "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := m.Link(ctx, owner.ID, result.LinkCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.UserID != owner.ID || linked.Provider != "mock" || linked.Subject != "owner-sub" {
+		t.Fatalf("linked identity %+v", linked)
+	}
+	signedIn, err := signIn(t, m, p, 0, "owner-sub", "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedIn.User.ID != owner.ID || signedIn.Created {
+		t.Fatalf("sign in with linked identity %+v", signedIn)
+	}
+
+	// The same provider account cannot be linked to a second user
+	result, err = signIn(t, m, p, victim.ID, "owner-sub", "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Link(ctx, victim.ID, result.LinkCode); !errors.Is(err, ErrIdentityInUse) {
+		t.Fatalf("identity of another user: %v, want ErrIdentityInUse", err)
+	}
+
+	// Link codes expire
+	result, err = signIn(t, m, p, victim.ID, "victim-sub", "victim@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Now().Add(codeTTL + time.Second) }
+	if _, err := m.Link(ctx, victim.ID, result.LinkCode); !errors.Is(err, ErrInvalidLinkCode) {
+		t.Fatalf("expired code: %v, want ErrInvalidLinkCode", err)
+	}
+}
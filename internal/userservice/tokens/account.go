@@ -0,0 +1,67 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoDeletionScheduled is returned when restoring an account that is not
+// scheduled for deletion
+var ErrNoDeletionScheduled = errors.New("account deletion not scheduled")
+
+// ErrDeletionInProgress is returned when restoring an account whose data is
+// already being erased
+var ErrDeletionInProgress = errors.New("account deletion in progress")
+
+// ScheduleDeletion marks the user's account for deletion once the grace
+// period has passed, signs them out everywhere and revokes their API keys.
+// It returns when the account will be deleted.
+func (i *Issuer) ScheduleDeletion(ctx context.Context, userID uint) (time.Time, revocation.UserVersion, error) {
+	now := i.now()
+	at := now.Add(i.deletionGrace)
+	var version revocation.UserVersion
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("deletion_scheduled_at", at).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		var err error
+		version, err = i.revokeAll(tx, userID)
+		return err
+	})
+	return at, version, err
+}
+
+// CancelDeletion restores an account scheduled for deletion unless its data
+// is already being erased
+func (i *Issuer) CancelDeletion(ctx context.Context, userID uint) error {
+	db := i.db.WithContext(ctx)
+	res := db.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL AND deleting_at IS NULL", userID).
+		Update("deletion_scheduled_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	var deleting int64
+	if err := db.Model(&models.User{}).Where("id = ? AND deleting_at IS NOT NULL", userID).Count(&deleting).Error; err != nil {
+		return err
+	}
+	if deleting > 0 {
+		return ErrDeletionInProgress
+	}
+	return ErrNoDeletionScheduled
+}
@@ -0,0 +1,44 @@
+package tokens
+
+import (
+	"context"
+
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+)
+
+// Disable marks the user as disabled and revokes all of their tokens
+func (i *Issuer) Disable(ctx context.Context, userID uint) (revocation.UserVersion, error) {
+	var version revocation.UserVersion
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ? AND disabled_at IS NULL", userID).
+			Update("disabled_at", i.now()).Error; err != nil {
+			return err
+		}
+		var err error
+		version, err = i.revokeAll(tx, userID)
+		return err
+	})
+	return version, err
+}
+
+// UpdateRoles replaces the user's roles and extra permissions and bumps the
+// token version, so access tokens carrying the old roles are rejected
+// everywhere. Refresh tokens stay valid and pick up the new roles.
+func (i *Issuer) UpdateRoles(ctx context.Context, userID uint, roles, permissions []string) (revocation.UserVersion, error) {
+	var user models.User
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: userID}).Select("roles", "permissions").
+			Updates(models.User{Roles: roles, Permissions: permissions}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Select("id", "token_version").First(&user, userID).Error
+	})
+	return revocation.UserVersion{UserID: user.ID, Version: user.TokenVersion}, err
+}
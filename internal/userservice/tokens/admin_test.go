@@ -0,0 +1,52 @@
+package tokens
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/models"
+)
+
+func TestUpdateRolesRevokesOldTokens(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIssuer(t)
+	if err := i.db.AutoMigrate(&models.RevokedToken{}, &models.Session{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "ayse", Email: "ayse@example.com", Roles: []string{"user", "moderator"}}
+	if err := i.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := i.UpdateRoles(ctx, user.ID, []string{"user"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.UserID != user.ID || version.Version != user.TokenVersion+1 {
+		t.Fatalf("version %+v", version)
+	}
+
+	var got models.User
+	if err := i.db.First(&got, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.Roles, []string{"user"}) {
+		t.Fatalf("roles %v", got.Roles)
+	}
+
+	// Services syncing revocations stop accepting the moderator's token
+	cache := revocation.NewCache(i.Revocations)
+	if err := cache.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	old := &identity.Identity{UserID: user.ID, Roles: []string{"user", "moderator"}, TokenVersion: user.TokenVersion}
+	if !cache.Revoked(old) {
+		t.Fatal("token with the old roles still accepted")
+	}
+	if cache.Revoked(&identity.Identity{UserID: user.ID, TokenVersion: version.Version}) {
+		t.Fatal("token with the new version revoked")
+	}
+}
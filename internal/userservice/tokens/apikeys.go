@@ -0,0 +1,158 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"enchanted-micro/internal/pkg/apikey"
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when revoking a key the user does not own
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrTooManyAPIKeys is returned when the user already has maxAPIKeys
+	// active keys
+	ErrTooManyAPIKeys = errors.New("too many api keys")
+	// ErrInvalidAPIKeyExpiry is returned for expiries in the past or beyond
+	// the configured maximum lifetime
+	ErrInvalidAPIKeyExpiry = errors.New("invalid api key expiry")
+)
+
+// maxAPIKeys limits the active keys a user may hold
+const maxAPIKeys = 20
+
+// lastUsedInterval is how stale last_used_at may get, so busy scripts do
+// not write on every request
+const lastUsedInterval = time.Minute
+
+// CreateAPIKey stores a new key for userID and returns it with the key in
+// clear; only its hash is kept. A nil expiresAt means the maximum lifetime.
+func (i *Issuer) CreateAPIKey(ctx context.Context, userID uint, name string, scopes []string, expiresAt *time.Time) (models.CreateAPIKeyResponse, error) {
+	now := i.now()
+	expires := now.Add(i.apiKeyMaxTTL)
+	if expiresAt != nil {
+		if !expiresAt.After(now) || expiresAt.After(expires) {
+			return models.CreateAPIKeyResponse{}, ErrInvalidAPIKeyExpiry
+		}
+		expires = *expiresAt
+	}
+
+	key, hash, display, err := apikey.Generate()
+	if err != nil {
+		return models.CreateAPIKeyResponse{}, err
+	}
+	record := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    display,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: expires,
+	}
+
+	err = i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the user serializes concurrent creates against the limit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.User{}, userID).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := activeAPIKeys(tx, userID, now).Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= maxAPIKeys {
+			return ErrTooManyAPIKeys
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return models.CreateAPIKeyResponse{}, err
+	}
+	return models.CreateAPIKeyResponse{APIKey: record, Key: key}, nil
+}
+
+// ListAPIKeys returns the user's keys that are neither revoked nor expired
+func (i *Issuer) ListAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := activeAPIKeys(i.db.WithContext(ctx), userID, i.now()).
+		Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey revokes one of the user's keys. Services caching
+// verifications stop accepting it once their cache entry expires.
+func (i *Issuer) RevokeAPIKey(ctx context.Context, userID, keyID uint) error {
+	res := i.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", i.now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// VerifyAPIKey resolves key to its owner. The identity carries the key's
+// scopes and expiry but no roles or permissions. Revoked and expired keys
+// and keys of disabled users or accounts scheduled for deletion yield
+// identity.ErrInvalidAPIKey.
+func (i *Issuer) VerifyAPIKey(ctx context.Context, key string) (*identity.Identity, error) {
+	if !apikey.Valid(key) {
+		return nil, identity.ErrInvalidAPIKey
+	}
+
+	db := i.db.WithContext(ctx)
+	now := i.now()
+	var record models.APIKey
+	err := db.Where("key_hash = ? AND revoked_at IS NULL AND expires_at > ?", apikey.Hash(key), now).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, identity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = db.First(&user, record.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, identity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ScheduleDeletion revokes every key, but a key created during the
+	// grace period must not work either
+	if user.Disabled() || user.DeletionScheduled() {
+		return nil, identity.ErrInvalidAPIKey
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= lastUsedInterval {
+		if err := db.Model(&record).Update("last_used_at", now).Error; err != nil {
+			slog.Warn("api key last use could not be recorded", "api_key_id", record.ID, "error", err)
+		}
+	}
+
+	return &identity.Identity{
+		UserID:        user.ID,
+		Username:      user.Username,
+		EmailVerified: user.EmailVerified(),
+		APIKeyID:      record.ID,
+		Scopes:        record.Scopes,
+		ExpiresAt:     record.ExpiresAt,
+	}, nil
+}
+
+func activeAPIKeys(tx *gorm.DB, userID uint, now time.Time) *gorm.DB {
+	return tx.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now)
+}
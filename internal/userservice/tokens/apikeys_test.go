@@ -0,0 +1,61 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestIssuer(t *testing.T) *Issuer {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.APIKey{}); err != nil {
+		t.Fatal(err)
+	}
+	return &Issuer{db: db, apiKeyMaxTTL: time.Hour, now: time.Now}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		user    models.User
+		wantErr error
+	}{
+		{name: "active user", user: models.User{Username: "active", Email: "active@example.com"}},
+		{name: "disabled user", user: models.User{Username: "disabled", Email: "disabled@example.com", DisabledAt: &now}, wantErr: identity.ErrInvalidAPIKey},
+		{name: "deletion scheduled", user: models.User{Username: "leaving", Email: "leaving@example.com", DeletionScheduledAt: &now}, wantErr: identity.ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newTestIssuer(t)
+			if err := i.db.Create(&tt.user).Error; err != nil {
+				t.Fatal(err)
+			}
+			created, err := i.CreateAPIKey(ctx, tt.user.ID, "script", []string{"products:read"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := i.VerifyAPIKey(ctx, created.Key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (id.UserID != tt.user.ID || id.APIKeyID != created.ID) {
+				t.Fatalf("identity %+v", id)
+			}
+		})
+	}
+}
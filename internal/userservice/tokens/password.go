@@ -0,0 +1,99 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken is returned for unknown, used or expired password
+// reset tokens
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+// PasswordReset issues a single-use password reset token for the user.
+// Earlier unused tokens of the user stop working.
+func (i *Issuer) PasswordReset(ctx context.Context, userID uint) (string, error) {
+	token, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+
+	now := i.now()
+	err = i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    userID,
+			TokenHash: randtoken.Hash(token),
+			ExpiresAt: now.Add(i.resetTTL),
+		}).Error
+	})
+	return token, err
+}
+
+// ResetPassword consumes a password reset token and stores passwordHash as
+// the user's new password. Every existing session of the user is revoked.
+func (i *Issuer) ResetPassword(ctx context.Context, token, passwordHash string) (revocation.UserVersion, error) {
+	var version revocation.UserVersion
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := i.now()
+
+		var reset models.PasswordResetToken
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", randtoken.Hash(token), now).First(&reset).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// Claim the token; a concurrent reset with the same token loses here
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		version, err = i.setPassword(tx, reset.UserID, passwordHash)
+		return err
+	})
+	return version, err
+}
+
+// ChangePassword stores passwordHash as the user's new password and revokes
+// every existing session of the user
+func (i *Issuer) ChangePassword(ctx context.Context, userID uint, passwordHash string) (revocation.UserVersion, error) {
+	var version revocation.UserVersion
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = i.setPassword(tx, userID, passwordHash)
+		return err
+	})
+	return version, err
+}
+
+func (i *Issuer) setPassword(tx *gorm.DB, userID uint, passwordHash string) (revocation.UserVersion, error) {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("password", passwordHash).Error; err != nil {
+		return revocation.UserVersion{}, err
+	}
+	// Outstanding reset links must not outlive the password they were sent for
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", i.now()).Error; err != nil {
+		return revocation.UserVersion{}, err
+	}
+	return i.revokeAll(tx, userID)
+}
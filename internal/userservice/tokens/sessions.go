@@ -0,0 +1,87 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSessionNotFound is returned when revoking a session the user does not
+// have or that already ended
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the user's sessions that are neither revoked nor
+// expired, most recently seen first. The session currentID belongs to is
+// marked as current.
+func (i *Issuer) ListSessions(ctx context.Context, userID uint, currentID string) ([]models.Session, error) {
+	sessions := []models.Session{}
+	err := i.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, i.now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	for n := range sessions {
+		sessions[n].Current = sessions[n].ID == currentID
+	}
+	return sessions, err
+}
+
+// RevokeSession ends one of the user's sessions: its refresh tokens stop
+// working and its access tokens are rejected once the returned revocation
+// reaches the services.
+func (i *Issuer) RevokeSession(ctx context.Context, userID uint, sessionID string) (revocation.Session, error) {
+	now := i.now()
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, now).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return i.revokeFamily(tx, session.ID, now)
+	})
+	if err != nil {
+		return revocation.Session{}, err
+	}
+	return i.revokedSession(sessionID, userID, now), nil
+}
+
+// touchSession records a refresh of current's family on its session.
+// Families issued before sessions were tracked get one on their first
+// refresh.
+func (i *Issuer) touchSession(tx *gorm.DB, current models.RefreshToken, client Client, now time.Time) error {
+	res := tx.Model(&models.Session{}).Where("id = ?", current.FamilyID).Updates(map[string]any{
+		"user_agent":   client.UserAgent,
+		"ip":           client.IP,
+		"last_seen_at": now,
+		"expires_at":   now.Add(i.refreshTTL),
+	})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	return tx.Create(&models.Session{
+		ID:         current.FamilyID,
+		UserID:     current.UserID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(i.refreshTTL),
+		CreatedAt:  now,
+	}).Error
+}
+
+// revokedSession is the revocation entry for a session revoked at
+// revokedAt; it is needed until the last access token of the session has
+// expired
+func (i *Issuer) revokedSession(sessionID string, userID uint, revokedAt time.Time) revocation.Session {
+	return revocation.Session{ID: sessionID, UserID: userID, ExpiresAt: revokedAt.Add(i.accessTTL)}
+}
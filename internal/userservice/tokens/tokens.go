@@ -0,0 +1,371 @@
+// Package tokens issues short-lived access tokens and rotating refresh
+// tokens for the user service.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"enchanted-micro/internal/pkg/identity"
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/pkg/revocation"
+	"enchanted-micro/internal/userservice/config"
+	"enchanted-micro/internal/userservice/keys"
+	"enchanted-micro/internal/userservice/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown or expired refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh
+	// token is presented; its whole family has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// reuseGrace is how long a rotated token may be presented again without
+// being treated as stolen
+const reuseGrace = 10 * time.Second
+
+// Client describes where a refresh token was issued
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Issuer signs access tokens and stores refresh tokens in db
+type Issuer struct {
+	db         *gorm.DB
+	keys       *keys.Manager
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	resetTTL   time.Duration
+	// Email verification token lifetime and minimum resend interval
+	verifyTTL    time.Duration
+	verifyResend time.Duration
+	apiKeyMaxTTL time.Duration
+	// How long a deleted account can be restored
+	deletionGrace time.Duration
+	now           func() time.Time
+}
+
+// NewIssuer builds an issuer from the service config; access tokens are
+// signed with the active key of signingKeys
+func NewIssuer(db *gorm.DB, cfg *config.Config, signingKeys *keys.Manager) *Issuer {
+	return &Issuer{
+		db:            db,
+		keys:          signingKeys,
+		accessTTL:     cfg.AccessTokenTTL,
+		refreshTTL:    cfg.RefreshTokenTTL,
+		resetTTL:      cfg.PasswordResetTTL,
+		verifyTTL:     cfg.EmailVerificationTTL,
+		verifyResend:  cfg.EmailVerificationResend,
+		apiKeyMaxTTL:  cfg.APIKeyMaxTTL,
+		deletionGrace: cfg.AccountDeletionGrace,
+		now:           time.Now,
+	}
+}
+
+// Login starts a session and issues an access token and the first refresh
+// token of its family
+func (i *Issuer) Login(ctx context.Context, user models.User, client Client) (models.TokenResponse, error) {
+	var tokens models.TokenResponse
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := i.now()
+		session := models.Session{
+			ID:         uuid.NewString(),
+			UserID:     user.ID,
+			UserAgent:  client.UserAgent,
+			IP:         client.IP,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(i.refreshTTL),
+			CreatedAt:  now,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		tokens, err = i.issue(tx, user, session.ID, client)
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh rotates a refresh token: the presented token is marked used and a
+// new one from the same family is returned with a fresh access token.
+// Presenting a token that was already rotated revokes the family.
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string, client Client) (models.TokenResponse, models.User, error) {
+	var (
+		tokens models.TokenResponse
+		user   models.User
+		reused bool
+	)
+
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", randtoken.Hash(refreshToken)).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		now := i.now()
+		if current.RevokedAt != nil {
+			// Logged out, or the family was already revoked
+			return ErrInvalidRefreshToken
+		}
+		if current.UsedAt != nil && now.Sub(*current.UsedAt) < reuseGrace {
+			// Two tabs refreshing at the same moment is not an attack
+			return ErrInvalidRefreshToken
+		}
+		if current.UsedAt != nil {
+			// The revocation must survive, so it is committed and the
+			// error is reported after the transaction
+			reused = true
+			user.ID = current.UserID
+			return i.revokeFamily(tx, current.FamilyID, now)
+		}
+		if now.After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if user.Disabled() {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := i.touchSession(tx, current, client, now); err != nil {
+			return err
+		}
+		tokens, err = i.issue(tx, user, current.FamilyID, client)
+		return err
+	})
+	if err == nil && reused {
+		err = ErrRefreshTokenReused
+	}
+	return tokens, user, err
+}
+
+// RevokeAccess revokes a single access token until it expires. Tokens
+// without an ID predate logout support and can only be revoked with
+// RevokeAll.
+func (i *Issuer) RevokeAccess(ctx context.Context, id *identity.Identity) (revocation.Token, error) {
+	token := revocation.Token{ID: id.TokenID, UserID: id.UserID, ExpiresAt: id.ExpiresAt}
+	if id.TokenID == "" {
+		return token, nil
+	}
+	err := i.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		TokenID:   token.ID,
+		UserID:    token.UserID,
+		ExpiresAt: token.ExpiresAt,
+	}).Error
+	return token, err
+}
+
+// RevokeFamily revokes the refresh token family refreshToken belongs to and
+// its session.
+// Tokens of other users are ignored so a caller cannot end someone else's
+// session.
+func (i *Issuer) RevokeFamily(ctx context.Context, userID uint, refreshToken string) error {
+	var current models.RefreshToken
+	err := i.db.WithContext(ctx).
+		Where("token_hash = ? AND user_id = ?", randtoken.Hash(refreshToken), userID).
+		First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return i.revokeFamily(i.db.WithContext(ctx), current.FamilyID, i.now())
+}
+
+// RevokeAll invalidates every access and refresh token of the user by
+// bumping the user's token version. It returns the new version.
+func (i *Issuer) RevokeAll(ctx context.Context, userID uint) (revocation.UserVersion, error) {
+	var version revocation.UserVersion
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = i.revokeAll(tx, userID)
+		return err
+	})
+	return version, err
+}
+
+// Revocations is the revocation.Source backed by the user database
+func (i *Issuer) Revocations(ctx context.Context, since time.Time) (*revocation.Delta, error) {
+	now := i.now()
+	db := i.db.WithContext(ctx)
+
+	var revoked []models.RevokedToken
+	if err := db.Where("created_at >= ? AND expires_at > ?", since, now).Find(&revoked).Error; err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := db.Select("id", "token_version").
+		Where("token_version > 0 AND updated_at >= ?", since).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	if err := db.Select("id", "user_id", "revoked_at").
+		Where("revoked_at >= ? AND revoked_at > ?", since, now.Add(-i.accessTTL)).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	delta := &revocation.Delta{Until: now}
+	for _, t := range revoked {
+		delta.Tokens = append(delta.Tokens, revocation.Token{ID: t.TokenID, UserID: t.UserID, ExpiresAt: t.ExpiresAt})
+	}
+	for _, s := range sessions {
+		delta.Sessions = append(delta.Sessions, i.revokedSession(s.ID, s.UserID, *s.RevokedAt))
+	}
+	for _, u := range users {
+		delta.Users = append(delta.Users, revocation.UserVersion{UserID: u.ID, Version: u.TokenVersion})
+	}
+	return delta, nil
+}
+
+// PurgeExpired deletes expired refresh tokens, sessions, revocation entries,
+// password reset and email verification tokens, login challenges and OIDC
+// sign in states and codes every interval until ctx is done.
+// Expired tokens are useless for rotation, and reuse of an expired token
+// cannot be told apart from an unknown one anyway.
+func (i *Issuer) PurgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for kind, model := range map[string]any{
+			"refresh":        &models.RefreshToken{},
+			"session":        &models.Session{},
+			"revoked":        &models.RevokedToken{},
+			"password_reset": &models.PasswordResetToken{},
+			"verification":   &models.EmailVerificationToken{},
+			"mfa_challenge":  &models.MFAChallenge{},
+			"oauth_state":    &models.OAuthState{},
+			"oauth_login":    &models.OAuthLoginCode{},
+			"oauth_link":     &models.OAuthLinkCode{},
+		} {
+			res := i.db.WithContext(ctx).Where("expires_at < ?", i.now()).Delete(model)
+			if res.Error != nil {
+				slog.Warn("expired token purge failed", "kind", kind, "error", res.Error)
+			} else if res.RowsAffected > 0 {
+				slog.Info("expired tokens purged", "kind", kind, "count", res.RowsAffected)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// revokeAll bumps the user's token version and revokes their refresh
+// tokens and sessions inside tx
+func (i *Issuer) revokeAll(tx *gorm.DB, userID uint) (revocation.UserVersion, error) {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return revocation.UserVersion{}, err
+	}
+	var user models.User
+	if err := tx.Select("id", "token_version").First(&user, userID).Error; err != nil {
+		return revocation.UserVersion{}, err
+	}
+	now := i.now()
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return revocation.UserVersion{}, err
+	}
+	err := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+	return revocation.UserVersion{UserID: user.ID, Version: user.TokenVersion}, err
+}
+
+// revokeFamily revokes a refresh token family and the session it belongs to
+func (i *Issuer) revokeFamily(tx *gorm.DB, familyID string, now time.Time) error {
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func (i *Issuer) issue(tx *gorm.DB, user models.User, familyID string, client Client) (models.TokenResponse, error) {
+	now := i.now()
+
+	access, err := i.accessToken(user, familyID, now)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	refresh, err := randtoken.New()
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: randtoken.Hash(refresh),
+		ExpiresAt: now.Add(i.refreshTTL),
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	return models.TokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(i.accessTTL / time.Second),
+	}, nil
+}
+
+func (i *Issuer) accessToken(user models.User, sessionID string, now time.Time) (string, error) {
+	kid, method, private, err := i.keys.Signing()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"user_id":        user.ID,
+		"username":       user.Username,
+		"email_verified": user.EmailVerified(),
+		"roles":          user.Roles,
+		"perms":          identity.Permissions(user.Roles, user.Permissions),
+		"jti":            uuid.NewString(),
+		"sid":            sessionID,
+		"ver":            user.TokenVersion,
+		"iat":            now.Unix(),
+		"exp":            now.Add(i.accessTTL).Unix(),
+	})
+	token.Header["kid"] = kid
+	return token.SignedString(private)
+}
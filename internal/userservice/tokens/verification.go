@@ -0,0 +1,95 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+
+	"enchanted-micro/internal/pkg/randtoken"
+	"enchanted-micro/internal/userservice/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidVerificationToken is returned for unknown, used or expired
+	// email verification tokens, and for tokens sent to a previous address
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+	// ErrVerificationThrottled is returned when a verification email was
+	// sent to the same address less than the resend interval ago
+	ErrVerificationThrottled = errors.New("email verification requested too often")
+)
+
+// EmailVerification issues a token confirming the user's current email
+// address. Earlier tokens stay valid until they expire.
+func (i *Issuer) EmailVerification(ctx context.Context, user models.User) (string, error) {
+	now := i.now()
+	db := i.db.WithContext(ctx)
+
+	var recent int64
+	if err := db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND email = ? AND created_at > ?", user.ID, user.Email, now.Add(-i.verifyResend)).
+		Count(&recent).Error; err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", ErrVerificationThrottled
+	}
+
+	token, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	err = db.Create(&models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: randtoken.Hash(token),
+		ExpiresAt: now.Add(i.verifyTTL),
+		CreatedAt: now,
+	}).Error
+	return token, err
+}
+
+// VerifyEmail consumes a verification token and marks the address it was
+// sent to as verified
+func (i *Issuer) VerifyEmail(ctx context.Context, token string) (models.User, error) {
+	var user models.User
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := i.now()
+
+		var verification models.EmailVerificationToken
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", randtoken.Hash(token), now).First(&verification).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, verification.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+		if user.Email != verification.Email {
+			return ErrInvalidVerificationToken
+		}
+
+		res := tx.Model(&models.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL", verification.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidVerificationToken
+		}
+
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Update("email_verified_at", now).Error
+		}
+		return nil
+	})
+	return user, err
+}
@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The appendix lists eight digit codes; six digit codes are their tail
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		for _, secret := range []string{rfcSecret, strings.ToLower(rfcSecret)} {
+			got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.code {
+				t.Errorf("T=%d: code %s, want %s", tt.unix, got, tt.code)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	tests := []struct {
+		name string
+		code string
+		step int64 // matched step, 0 when rejected
+	}{
+		{name: "current step", code: "050471", step: step},
+		{name: "previous step", code: "081804", step: step - 1},
+		{name: "next step", code: mustCode(t, step+1), step: step + 1},
+		{name: "two steps back", code: mustCode(t, step-2)},
+		{name: "two steps ahead", code: mustCode(t, step+2)},
+		{name: "spaces", code: "050 471", step: step},
+		{name: "too short", code: "05047"},
+		{name: "too long", code: "0504711"},
+		{name: "wrong", code: "123456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now)
+			if ok != (tt.step != 0) || got != tt.step {
+				t.Fatalf("Validate(%q) = %d, %v; want step %d", tt.code, got, ok, tt.step)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "123456", time.Now()); ok {
+		t.Fatal("accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(secret, code, now); !ok {
+		t.Fatal("own code rejected")
+	}
+}
+
+func mustCode(t *testing.T, step int64) string {
+	t.Helper()
+	code, err := Code(rfcSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
@@ -2,29 +2,37 @@
 
 echo "🏥 Health Check for Enchanted Microservices..."
 
-# Check User Service
-echo "Checking User Service..."
-if curl -f http://localhost:8080/health > /dev/null 2>&1; then
-    echo "✅ User Service: Healthy"
-else
-    echo "❌ User Service: Unhealthy"
-fi
+# check_ready NAME URL - queries a /health/ready endpoint and prints the
+# per-dependency breakdown it returns
+check_ready() {
+    local name="$1"
+    local url="$2"
 
-# Check Product Service
-echo "Checking Product Service..."
-if curl -f http://localhost:8081/health > /dev/null 2>&1; then
-    echo "✅ Product Service: Healthy"
-else
-    echo "❌ Product Service: Unhealthy"
-fi
+    echo "Checking $name..."
+    local body
+    body=$(curl -s -w '\n%{http_code}' "$url" 2>/dev/null)
+    local code="${body##*$'\n'}"
+    body="${body%$'\n'*}"
 
-# Check API Gateway
-echo "Checking API Gateway..."
-if curl -f http://localhost:8090/health > /dev/null 2>&1; then
-    echo "✅ API Gateway: Healthy"
-else
-    echo "❌ API Gateway: Unhealthy"
-fi
+    if [ "$code" = "200" ]; then
+        echo "✅ $name: Ready"
+    elif [ -z "$body" ]; then
+        echo "❌ $name: Unreachable"
+        return
+    else
+        echo "❌ $name: Not ready (HTTP $code)"
+    fi
+
+    if command -v jq > /dev/null 2>&1 && [ -n "$body" ]; then
+        echo "$body" | jq -r '.checks // {} | to_entries[] | "   - \(.key): \(.value.status)\(if .value.error then " (\(.value.error))" else "" end)"'
+    fi
+}
+
+check_ready "User Service" "http://localhost:8080/health/ready"
+check_ready "Product Service" "http://localhost:8081/health/ready"
+
+# The gateway's readiness covers every upstream it routes to
+check_ready "API Gateway" "http://localhost:8090/health/ready"
 
 # Check Frontend
 echo "Checking Frontend..."